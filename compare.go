@@ -0,0 +1,161 @@
+package main
+
+import (
+    "encoding/csv"
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "strconv"
+    "text/tabwriter"
+
+    "github.com/franciscobonand/symb-regr-gp/stats"
+)
+
+// sample holds the final results of the runs of a configuration
+type sample struct {
+    name   string
+    values []float64
+}
+
+// compareMain runs the 'compare' command, which reads the final results of the
+// runs of two or more configurations and tests whether they differ
+func compareMain(args []string) {
+    // ./symb-regr-gp compare -metric testfit tour-runs.csv lex-runs.csv
+    var metric string
+    var alpha float64
+    fs := flag.NewFlagSet("compare", flag.ExitOnError)
+    fs.StringVar(&metric, "metric", "trainfit", "column of the final results to be compared ('trainfit', 'testfit' or 'size')")
+    fs.Float64Var(&alpha, "alpha", 0.05, "significance level of the tests")
+    fs.Usage = func() {
+        fmt.Fprintln(fs.Output(), "Usage of compare: compare [flags] file...")
+        fmt.Fprintln(fs.Output(), "Each file has the final results of the runs of a configuration (written by -summaryfile),")
+        fmt.Fprintln(fs.Output(), "or of many configurations identified by a 'config' column (written by sweep -runsfile)")
+        fs.PrintDefaults()
+    }
+    fs.Parse(args)
+    if alpha <= 0.0 || alpha >= 1.0 {
+        panic("Significance level must be between 0.0 and 1.0 (exclusive)")
+    }
+
+    samples := []sample{}
+    for _, fname := range fs.Args() {
+        s, err := readSamples(fname, metric)
+        if err != nil {
+            panic(err.Error())
+        }
+        samples = append(samples, s...)
+    }
+    if len(samples) < 2 {
+        panic("At least two configurations must be compared")
+    }
+    for _, s := range samples {
+        if len(s.values) == 0 {
+            panic(fmt.Sprintf("Configuration '%s' has no valid values of '%s'", s.name, metric))
+        }
+    }
+
+    w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "config\tn\tmean\tstd\tmedian\tq1\tq3\t")
+    groups := make([][]float64, len(samples))
+    for i, s := range samples {
+        d := stats.Describe(s.values)
+        fmt.Fprintf(w, "%s\t%d\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t\n", s.name, len(s.values), d.Mean, d.Std, d.Median, d.Q1, d.Q3)
+        groups[i] = s.values
+    }
+    w.Flush()
+
+    h, p := stats.KruskalWallis(groups...)
+    fmt.Printf("\nKruskal-Wallis: H = %.4f, p = %.4g%s\n\n", h, p, significance(p, alpha))
+
+    type pair struct {
+        a, b int
+        u, p float64
+        a12  float64
+    }
+    pairs := []pair{}
+    pvalues := []float64{}
+    for i := range samples {
+        for j := i + 1; j < len(samples); j++ {
+            u, p := stats.MannWhitney(samples[i].values, samples[j].values)
+            pairs = append(pairs, pair{i, j, u, p, stats.A12(samples[i].values, samples[j].values)})
+            pvalues = append(pvalues, p)
+        }
+    }
+    holm := stats.Holm(pvalues)
+    fmt.Fprintln(w, "config A\tconfig B\tU\tp\tp (Holm)\tA12\teffect\t")
+    for k, pr := range pairs {
+        fmt.Fprintf(w, "%s\t%s\t%.1f\t%.4g\t%.4g%s\t%.3f\t%s\t\n",
+            samples[pr.a].name,
+            samples[pr.b].name,
+            pr.u,
+            pr.p,
+            holm[k],
+            significance(holm[k], alpha),
+            pr.a12,
+            stats.EffectMagnitude(pr.a12),
+        )
+    }
+    w.Flush()
+    fmt.Printf("\nA12 is the probability of a run of A having a greater %s than a run of B. (*) p < %g\n", metric, alpha)
+}
+
+func significance(p, alpha float64) string {
+    if p < alpha {
+        return " (*)"
+    }
+    return ""
+}
+
+// readSamples reads the values of the metric column of a csv file of final results.
+// Files with a 'config' column have a sample per configuration, named after the
+// file and the configuration, and NaN values are left out
+func readSamples(fname, metric string) ([]sample, error) {
+    f, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    lines, err := csv.NewReader(f).ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("%s: %s", fname, err.Error())
+    }
+    if len(lines) == 0 {
+        return nil, fmt.Errorf("%s: empty file", fname)
+    }
+    col, cfgcol := -1, -1
+    for i, name := range lines[0] {
+        switch name {
+        case metric:
+            col = i
+        case "config":
+            cfgcol = i
+        }
+    }
+    if col < 0 {
+        return nil, fmt.Errorf("%s: no '%s' column", fname, metric)
+    }
+
+    samples := []sample{}
+    index := map[string]int{}
+    for n, line := range lines[1:] {
+        name := fname
+        if cfgcol >= 0 {
+            name = fmt.Sprintf("%s#%s", fname, line[cfgcol])
+        }
+        i, ok := index[name]
+        if !ok {
+            i = len(samples)
+            index[name] = i
+            samples = append(samples, sample{name: name})
+        }
+        v, err := strconv.ParseFloat(line[col], 64)
+        if err != nil {
+            return nil, fmt.Errorf("%s: line %d: invalid %s '%s'", fname, n+2, metric, line[col])
+        }
+        if !math.IsNaN(v) {
+            samples[i].values = append(samples[i].values, v)
+        }
+    }
+    return samples, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+
+    "github.com/franciscobonand/symb-regr-gp/datasets"
+    "github.com/franciscobonand/symb-regr-gp/stats"
+)
+
+// convertMain runs the 'convert' command, which reads a dataset file of any
+// format and writes it in the binary columnar format, for fast reloads
+func convertMain(args []string) {
+    // ./symb-regr-gp convert -missing mean data.tsv.gz data.bin
+    var format, missing, weight string
+    fs := flag.NewFlagSet("convert", flag.ExitOnError)
+    fs.StringVar(&format, "format", "auto", "format of the input file ('auto', 'csv', 'tsv', 'whitespace', 'jsonl' or 'binary')")
+    fs.StringVar(&weight, "weight", "", "if set, name of the input column with the weight of each row, written as the 'weight' column")
+    fs.StringVar(&missing, "missing", "fail", "policy for rows with missing or non-finite values ('fail', 'skip', 'mean' or 'median')")
+    fs.Usage = func() {
+        fmt.Fprintln(fs.Output(), "Usage of convert: convert [flags] input output")
+        fs.PrintDefaults()
+    }
+    fs.Parse(args)
+    if fs.NArg() != 2 {
+        fs.Usage()
+        os.Exit(2)
+    }
+
+    opts := dataset.ReadOptions{ Format: dataset.Format(format), Missing: dataset.MissingPolicy(missing), Weight: weight }
+    ds, report, err := dataset.ReadWith(fs.Arg(0), opts)
+    if err != nil {
+        panic(err.Error())
+    }
+    printReadReport(fs.Arg(0), report)
+    f, err := stats.CreateFile(fs.Arg(1))
+    if err != nil {
+        panic(err.Error())
+    }
+    defer f.Close()
+    if err := dataset.WriteBinary(f, ds); err != nil {
+        panic(err.Error())
+    }
+    fmt.Fprintf(os.Stderr, "%s: %d rows and %d variables written\n", fs.Arg(1), ds.Rows(), len(ds.Variables))
+}
@@ -2,89 +2,521 @@ package dataset
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
-	"io/fs"
+	"io"
+	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 // Dataset defines the variables (x0, x1, x2...), the inputs which are the values
-// a variable can assume and the expected output given a set of inputs
+// a variable can assume and the expected output given a set of inputs.
+// Inputs are stored column-major in a single slice, the values of each variable
+// being contiguous, so trees are evaluated on many rows in a cache-friendly way.
+// Weights, if not nil, has the importance of each row in the fitness evaluation
 type Dataset struct {
-    Input [][]float64
+    Input []float64
     Output []float64
     Variables []string
+    Weights []float64
 }
 
+// Copy returns a deep copy of the dataset
 func (ds Dataset) Copy() Dataset {
-    input := make([][]float64, len(ds.Input))
-    input = append(input, ds.Input...)
-    output := []float64{}
-    output = append(output, ds.Output...)
-    return Dataset{
-        Input: input,
-        Output: output,
+    out := Dataset{
+        Input: append([]float64{}, ds.Input...),
+        Output: append([]float64{}, ds.Output...),
+        Variables: append([]string{}, ds.Variables...),
     }
+    if ds.Weights != nil {
+        out.Weights = append([]float64{}, ds.Weights...)
+    }
+    return out
+}
+
+// Rows returns the number of rows of the dataset
+func (ds *Dataset) Rows() int {
+    return len(ds.Output)
+}
+
+// Column returns the values of the j-th variable on every row
+func (ds *Dataset) Column(j int) []float64 {
+    n := ds.Rows()
+    return ds.Input[j*n : (j+1)*n : (j+1)*n]
+}
+
+// InputColumns returns the values of every variable, as taken by Expr.EvalColumns
+func (ds *Dataset) InputColumns() [][]float64 {
+    cols := make([][]float64, len(ds.Variables))
+    for j := range cols {
+        cols[j] = ds.Column(j)
+    }
+    return cols
 }
 
-// Read reads a file resided in the given path.
+// Row returns the values of the variables on the i-th row, stored in buf if it's big enough
+func (ds *Dataset) Row(i int, buf []float64) []float64 {
+    if cap(buf) < len(ds.Variables) {
+        buf = make([]float64, len(ds.Variables))
+    }
+    buf = buf[:len(ds.Variables)]
+    n := ds.Rows()
+    for j := range buf {
+        buf[j] = ds.Input[j*n+i]
+    }
+    return buf
+}
+
+// Weight returns the weight of the i-th row, which is 1 if the dataset isn't weighted
+func (ds *Dataset) Weight(i int) float64 {
+    if ds.Weights == nil {
+        return 1.0
+    }
+    return ds.Weights[i]
+}
+
+// Bytes returns the memory used by the values of the dataset
+func (ds *Dataset) Bytes() int64 {
+    return int64(len(ds.Input)+len(ds.Output)+len(ds.Weights)) * 8
+}
+
+// MissingPolicy defines what is done with rows that have missing or non-finite values
+type MissingPolicy string
+
+const (
+    // FailMissing makes reading fail
+    FailMissing MissingPolicy = "fail"
+    // SkipMissing leaves the row out of the dataset. Malformed and ragged rows are also skipped
+    SkipMissing MissingPolicy = "skip"
+    // MeanMissing replaces missing inputs by the mean of their column
+    MeanMissing MissingPolicy = "mean"
+    // MedianMissing replaces missing inputs by the median of their column
+    MedianMissing MissingPolicy = "median"
+)
+
+// ReadOptions defines how a dataset file is read
+type ReadOptions struct {
+    // Format is the format of the file, detected from its contents if empty
+    Format  Format
+    Missing MissingPolicy
+    // Weight, if not empty, is the name of the column with the weight of each row.
+    // Columns are named by the header or, if there's none, as x0, x1, x2...
+    // Binary files written with weights already have them, and Weight must be empty
+    Weight  string
+    // Fill, if not nil, has the values missing inputs of each column are replaced by,
+    // instead of their mean or median. It's used to fill a test dataset with the
+    // values computed on the training dataset
+    Fill []float64
+}
+
+// ReadReport describes how the rows of a dataset file were handled
+type ReadReport struct {
+    Rows    int
+    Skipped int
+    Imputed int
+    // Fill has the values missing inputs of each column were replaced by
+    Fill    []float64
+    // Bytes is the memory used by the values of the dataset
+    Bytes   int64
+}
+
+// ParseError is an error in a value of a dataset file.
+// Lines and columns start at 1
+type ParseError struct {
+    Line, Column int
+    Msg          string
+}
+
+func (e *ParseError) Error() string {
+    return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Read reads a file resided in the given path, failing if any value is missing.
 // The path is relative to the directory the program is executed
 func Read(fpath string) (*Dataset, error) {
+    ds, _, err := ReadWith(fpath, ReadOptions{ Missing: FailMissing })
+    return ds, err
+}
+
+// ReadWith reads a file resided in the given path, handling missing values with the given policy.
+// Empty cells and values like 'NA', '?' or 'NaN' are missing, as well as infinite values.
+// Rows with a missing output are skipped if missing values are imputed.
+// The file is streamed, so lines may have any length, and gzip-compressed files are
+// decompressed. A first row without numbers is a header naming the variables.
+// The path is relative to the directory the program is executed
+func ReadWith(fpath string, opts ReadOptions) (*Dataset, ReadReport, error) {
+    report := ReadReport{}
+    switch opts.Missing {
+    case FailMissing, SkipMissing, MeanMissing, MedianMissing:
+    default:
+        return nil, report, fmt.Errorf("unknown missing value policy '%s'", opts.Missing)
+    }
+    format := opts.Format
+    if format == "" {
+        format = AutoFormat
+    }
+    if _, err := ParseFormat(string(format)); err != nil {
+        return nil, report, err
+    }
     f, err := os.Open(fpath)
     if err != nil {
-        return nil, err
+        return nil, report, err
     }
     defer f.Close()
+    r, err := decompress(f)
+    if err != nil {
+        return nil, report, fmt.Errorf("%s: %w", fpath, err)
+    }
+
+    detected, delim := sniff(r)
+    if format == AutoFormat {
+        format = detected
+    }
+    var names []string
+    var cols [][]float64
+    weighted := false
+    switch format {
+    case BinaryFormat:
+        if names, cols, weighted, err = readBinary(r); err == nil {
+            cols, err = dropMissing(cols, opts.Missing, &report)
+        }
+    case JSONLinesFormat:
+        cols, err = readRecords(jsonRecords(r, &names), opts.Missing, &report, nil)
+    case WhitespaceFormat:
+        cols, err = readRecords(whitespaceRecords(r), opts.Missing, &report, &names)
+    default:
+        if format == TSVFormat {
+            delim = '\t'
+        } else if delim != ';' {
+            delim = ','
+        }
+        cols, err = readRecords(delimitedRecords(r, delim), opts.Missing, &report, &names)
+    }
+    if err != nil {
+        return nil, report, fmt.Errorf("%s: %w", fpath, err)
+    }
+    if len(cols) == 0 || len(cols[0]) == 0 {
+        return nil, report, fmt.Errorf("%s: no valid rows", fpath)
+    }
+    var weights []float64
+    if weighted {
+        if opts.Weight != "" {
+            return nil, report, fmt.Errorf("%s: the file already has weights", fpath)
+        }
+        if cols, names, weights, err = removeWeights(cols, names, len(cols)-2, &report); err != nil {
+            return nil, report, fmt.Errorf("%s: %w", fpath, err)
+        }
+    } else if opts.Weight != "" {
+        if cols, names, weights, err = extractWeights(cols, names, opts.Weight, &report); err != nil {
+            return nil, report, fmt.Errorf("%s: %w", fpath, err)
+        }
+    }
+    ncols := len(cols)
+
+    if opts.Missing == MeanMissing || opts.Missing == MedianMissing {
+        report.Fill = opts.Fill
+        if report.Fill == nil {
+            report.Fill = fillValues(cols[:ncols-1], opts.Missing)
+        }
+        if len(report.Fill) != ncols-1 {
+            return nil, report, fmt.Errorf("%s: expected %d input columns, found %d", fpath, len(report.Fill), ncols-1)
+        }
+        for j, col := range cols[:ncols-1] {
+            for i, v := range col {
+                if math.IsNaN(v) {
+                    if math.IsNaN(report.Fill[j]) {
+                        return nil, report, fmt.Errorf("%s: column %d has no values to impute missing ones", fpath, j+1)
+                    }
+                    col[i] = report.Fill[j]
+                    report.Imputed++
+                }
+            }
+        }
+    }
+
+    ds := fromColumns(cols, names)
+    ds.Weights = weights
+    report.Rows = ds.Rows()
+    report.Bytes = ds.Bytes()
+    return ds, report, nil
+}
+
+// readRecords parses the records of a text format into columns, handling missing values
+// with the given policy. If header isn't nil, a first record without numbers is stored in it
+func readRecords(next recordReader, policy MissingPolicy, report *ReadReport, header *[]string) ([][]float64, error) {
+    // values are stored in fixed size chunks per column, which aren't copied as
+    // they grow, and are moved to a single buffer once the number of rows is known
+    var chunks [][][]float64
+    var row []float64
+    rows := 0
+    for first := true; ; first = false {
+        items, line, err := next()
+        if err == io.EOF {
+            return contiguous(chunks, rows), nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        if chunks == nil {
+            if len(items) < 2 {
+                return nil, &ParseError{line, 1, "at least one input and one output column are needed"}
+            }
+            chunks = make([][][]float64, len(items))
+            row = make([]float64, len(items))
+        }
+        if first && header != nil && isHeader(items) {
+            *header = make([]string, len(items))
+            for i, item := range items {
+                (*header)[i] = strings.TrimSpace(item)
+            }
+            continue
+        }
+        if perr := parseRow(items, row, line, policy); perr != nil {
+            if policy != SkipMissing {
+                return nil, perr
+            }
+            report.Skipped++
+        } else if math.IsNaN(row[len(row)-1]) {
+            report.Skipped++
+        } else {
+            if rows % chunkSize == 0 {
+                for j := range chunks {
+                    chunks[j] = append(chunks[j], make([]float64, chunkSize))
+                }
+            }
+            for j, v := range row {
+                chunks[j][rows / chunkSize][rows % chunkSize] = v
+            }
+            rows++
+        }
+    }
+}
+
+// chunkSize is the number of values of each chunk of a column read by readRecords
+const chunkSize = 1 << 16
+
+// contiguous copies the chunks of each column, which have the given number of
+// rows, into a single column-major buffer and returns the columns as consecutive
+// parts of it. Chunks are released as they're copied, but both are kept in
+// memory until the copy ends, needing about twice the size of the values
+func contiguous(chunks [][][]float64, rows int) [][]float64 {
+    if chunks == nil {
+        return nil
+    }
+    buf := make([]float64, len(chunks) * rows)
+    cols := make([][]float64, len(chunks))
+    for j := range chunks {
+        cols[j] = buf[j*rows : (j+1)*rows]
+        for k, chunk := range chunks[j] {
+            copy(cols[j][k*chunkSize:], chunk)
+            chunks[j][k] = nil
+        }
+    }
+    return cols
+}
+
+// extractWeights removes the weight column of the given name from the columns
+// and their names, returning its values. Rows with missing weights are skipped
+func extractWeights(cols [][]float64, names []string, name string, report *ReadReport) ([][]float64, []string, []float64, error) {
+    if names == nil {
+        names = make([]string, len(cols))
+        for j := range names {
+            names[j] = fmt.Sprintf("x%d", j)
+        }
+    }
+    idx := -1
+    for j, n := range names[:len(names)-1] {
+        if n == name {
+            idx = j
+            break
+        }
+    }
+    if idx < 0 {
+        return nil, nil, nil, fmt.Errorf("no input column named '%s' for the weights", name)
+    }
+    return removeWeights(cols, names, idx, report)
+}
+
+// removeWeights removes the weight column at idx from the columns and their
+// names, returning its values. Rows with missing weights are skipped
+func removeWeights(cols [][]float64, names []string, idx int, report *ReadReport) ([][]float64, []string, []float64, error) {
+    if len(cols) < 3 {
+        return nil, nil, nil, fmt.Errorf("at least one input column is needed besides the weights")
+    }
+    weights := cols[idx]
+    cols = append(append([][]float64{}, cols[:idx]...), cols[idx+1:]...)
+    names = append(append([]string{}, names[:idx]...), names[idx+1:]...)
+
+    keep := 0
+    total := 0.0
+    for i, w := range weights {
+        if math.IsNaN(w) {
+            report.Skipped++
+            continue
+        }
+        if w < 0 {
+            return nil, nil, nil, fmt.Errorf("row %d: negative weight %g", i+1, w)
+        }
+        for _, col := range cols {
+            col[keep] = col[i]
+        }
+        weights[keep] = w
+        total += w
+        keep++
+    }
+    if keep > 0 && total <= 0 {
+        return nil, nil, nil, fmt.Errorf("every weight is zero")
+    }
+    for j := range cols {
+        cols[j] = cols[j][:keep]
+    }
+    // weights are copied, so they don't keep the buffer of the columns
+    return cols, names, append([]float64{}, weights[:keep]...), nil
+}
+
+// isHeader reports whether none of the items is a number or a missing value
+func isHeader(items []string) bool {
+    for _, item := range items {
+        if isMissing(item) {
+            return false
+        }
+        if _, err := strconv.ParseFloat(strings.TrimSpace(item), 64); err == nil {
+            return false
+        }
+    }
+    return true
+}
+
+// isMissing reports whether the item is a missing value
+func isMissing(item string) bool {
+    switch strings.ToLower(strings.TrimSpace(item)) {
+    case "", "na", "nan", "?", "null":
+        return true
+    }
+    return false
+}
+
+// decompress returns a buffered reader of the contents of r, which are
+// decompressed if they start with the gzip magic number
+func decompress(r io.Reader) (*bufio.Reader, error) {
+    br := bufio.NewReaderSize(r, sniffSize)
+    magic, _ := br.Peek(2)
+    if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+        zr, err := gzip.NewReader(br)
+        if err != nil {
+            return nil, err
+        }
+        return bufio.NewReaderSize(zr, sniffSize), nil
+    }
+    return br, nil
+}
 
-    ds := Dataset{}
-    ds.Input = [][]float64{}
-    scanner := bufio.NewScanner(f)
-    count := 0
-    for scanner.Scan() {
-        inputs := []float64{}
-        line := scanner.Text()
-        items := strings.Split(line, ",")
-        for i, str := range items {
+// fromColumns returns the dataset whose inputs are every column but the last one, the output.
+// Variables are named after the columns, if names aren't nil, or as x0, x1, x2...
+// Input columns that are consecutive parts of a buffer, as the ones read from
+// files, become the input as they are. Otherwise they're copied to a new
+// buffer, and so is the output, so the dataset doesn't keep the old one
+func fromColumns(cols [][]float64, names []string) *Dataset {
+    n := len(cols[0])
+    inputs := cols[:len(cols)-1]
+    ds := &Dataset{ Output: cols[len(cols)-1] }
+    if consecutive(inputs) {
+        ds.Input = inputs[0][: n*len(inputs) : n*len(inputs)]
+    } else {
+        ds.Input = make([]float64, 0, n*len(inputs))
+        for _, col := range inputs {
+            ds.Input = append(ds.Input, col...)
+        }
+        ds.Output = append([]float64{}, ds.Output...)
+    }
+    for j := range inputs {
+        if len(names) == len(cols) {
+            ds.Variables = append(ds.Variables, names[j])
+        } else {
+            ds.Variables = append(ds.Variables, fmt.Sprintf("x%d", j))
+        }
+    }
+    return ds
+}
+
+// consecutive reports whether the columns have the same number of rows and
+// are stored one after the other in the same buffer
+func consecutive(cols [][]float64) bool {
+    n := len(cols[0])
+    if n == 0 || cap(cols[0]) < n*len(cols) {
+        return false
+    }
+    whole := cols[0][:n*len(cols)]
+    for j, col := range cols {
+        if len(col) != n || &whole[j*n] != &col[0] {
+            return false
+        }
+    }
+    return true
+}
+
+// parseRow parses the values of a line into row, returning a ParseError if the line is
+// malformed or, unless they are imputed, has missing values. Missing values are NaN
+func parseRow(items []string, row []float64, line int, policy MissingPolicy) *ParseError {
+    ncols := len(row)
+    if len(items) != ncols {
+        return &ParseError{line, minInt(len(items), ncols) + 1, fmt.Sprintf("expected %d columns, found %d", ncols, len(items))}
+    }
+    for i, item := range items {
+        str := strings.TrimSpace(item)
+        if isMissing(str) {
+            row[i] = math.NaN()
+        } else {
             num, err := strconv.ParseFloat(str, 64)
             if err != nil {
-                return nil, err
+                return &ParseError{line, i + 1, fmt.Sprintf("invalid number '%s'", str)}
             }
-            if i == len(items) - 1 {
-                ds.Output = append(ds.Output, num) 
-                break
+            if math.IsInf(num, 0) {
+                num = math.NaN()
             }
-            inputs = append(inputs, num)
+            row[i] = num
+        }
+        if math.IsNaN(row[i]) && (policy == FailMissing || policy == SkipMissing) {
+            return &ParseError{line, i + 1, fmt.Sprintf("missing or non-finite value '%s'", str)}
         }
-        ds.Input = append(ds.Input, inputs)
-        count++;
     }
+    return nil
+}
 
-    for i := range ds.Input[0] {
-        ds.Variables = append(ds.Variables, fmt.Sprintf("x%d", i))
+// fillValues returns the mean or median of the non missing values of each column
+func fillValues(cols [][]float64, policy MissingPolicy) []float64 {
+    fill := make([]float64, len(cols))
+    for j := range fill {
+        col := []float64{}
+        for _, v := range cols[j] {
+            if !math.IsNaN(v) {
+                col = append(col, v)
+            }
+        }
+        if len(col) == 0 {
+            fill[j] = math.NaN()
+            continue
+        }
+        if policy == MedianMissing {
+            sort.Float64s(col)
+            fill[j] = (col[(len(col)-1)/2] + col[len(col)/2]) / 2
+            continue
+        }
+        var sum float64
+        for _, v := range col {
+            sum += v
+        }
+        fill[j] = sum / float64(len(col))
     }
-
-    return &ds, nil
+    return fill
 }
 
-func Write(fname string, data [][]float64) error {
-    content := "gen,evals,repeated,bestfit,worstfit,meanfit,maxsize,minsize,meansize,betterCxChild,worseCxChild\n"
-    for i, line := range data {
-        content += fmt.Sprintf("%d,%f,%f,%f,%f,%f,%f,%f,%f,%f,%f\n",
-            i,
-            line[0],
-            line[1],
-            line[2],
-            line[3],
-            line[4],
-            line[5],
-            line[6],
-            line[7],
-            line[8],
-            line[9],
-        )
+func minInt(a, b int) int {
+    if a < b {
+        return a
     }
-    bcontent := []byte(content)
-    f := fmt.Sprintf("analysis/%s", fname)
-    return os.WriteFile(f, bcontent, fs.ModePerm)
+    return b
 }
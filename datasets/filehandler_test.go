@@ -0,0 +1,38 @@
+package dataset
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestReadRecordsAcrossChunks reads more rows than a chunk holds and checks
+// every value lands in its place of the column-major input, which isn't copied
+func TestReadRecordsAcrossChunks(t *testing.T) {
+    rows := chunkSize + 3
+    var text strings.Builder
+    for i := 0; i < rows; i++ {
+        fmt.Fprintf(&text, "%d %d %d\n", i, -i, 2 * i)
+    }
+    report := ReadReport{}
+    cols, err := readRecords(whitespaceRecords(bufio.NewReader(strings.NewReader(text.String()))), FailMissing, &report, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !consecutive(cols[:2]) {
+        t.Error("input columns aren't consecutive parts of a buffer")
+    }
+    ds := fromColumns(cols, nil)
+    if ds.Rows() != rows {
+        t.Fatalf("got %d rows, want %d", ds.Rows(), rows)
+    }
+    for i := 0; i < rows; i++ {
+        if ds.Input[i] != float64(i) || ds.Input[rows + i] != float64(-i) || ds.Output[i] != float64(2 * i) {
+            t.Fatalf("row %d: got %v and %g", i, ds.Row(i, nil), ds.Output[i])
+        }
+    }
+    if &ds.Input[0] != &cols[0][0] {
+        t.Error("consecutive input columns were copied")
+    }
+}
@@ -0,0 +1,390 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Format is the format of a dataset file
+type Format string
+
+const (
+    // AutoFormat detects the format from the contents of the file
+    AutoFormat Format = "auto"
+    // CSVFormat has comma separated values, which may be quoted
+    CSVFormat Format = "csv"
+    // TSVFormat has tab separated values, which may be quoted
+    TSVFormat Format = "tsv"
+    // WhitespaceFormat has values separated by any amount of spaces or tabs, as PMLB and SRBench files
+    WhitespaceFormat Format = "whitespace"
+    // JSONLinesFormat has a JSON array of values or a JSON object per line,
+    // the keys of the first object naming the columns
+    JSONLinesFormat Format = "jsonl"
+    // BinaryFormat is the columnar format written by WriteBinary
+    BinaryFormat Format = "binary"
+)
+
+// binaryMagic starts every file of the binary format, whose header has flags
+// since version 2. Files of version 1 are still read
+const (
+    binaryMagic   = "SRGPCOL2"
+    binaryMagicV1 = "SRGPCOL1"
+)
+
+// binaryWeighted is the flag of binary files whose column before the output has the weights
+const binaryWeighted uint8 = 1
+
+// sniffSize is the number of bytes inspected to detect the format of a file
+const sniffSize = 64 * 1024
+
+// ParseFormat returns the format of the given name
+func ParseFormat(name string) (Format, error) {
+    switch f := Format(name); f {
+    case AutoFormat, CSVFormat, TSVFormat, WhitespaceFormat, JSONLinesFormat, BinaryFormat:
+        return f, nil
+    }
+    return "", fmt.Errorf("unknown format '%s', must be 'auto', 'csv', 'tsv', 'whitespace', 'jsonl' or 'binary'", name)
+}
+
+// sniff detects the format of the contents of r without consuming them,
+// returning the delimiter of its values if they're delimited
+func sniff(r *bufio.Reader) (Format, rune) {
+    head, _ := r.Peek(sniffSize)
+    if bytes.HasPrefix(head, []byte(binaryMagic)) || bytes.HasPrefix(head, []byte(binaryMagicV1)) {
+        return BinaryFormat, 0
+    }
+    head = bytes.TrimLeft(head, " \t\r\n")
+    if len(head) > 0 && (head[0] == '{' || head[0] == '[') {
+        return JSONLinesFormat, 0
+    }
+    if end := bytes.IndexByte(head, '\n'); end >= 0 {
+        head = head[:end]
+    }
+    // the delimiter is the most frequent candidate outside of quotes
+    counts := map[rune]int{}
+    quoted := false
+    for _, c := range string(head) {
+        switch c {
+        case '"':
+            quoted = !quoted
+        case ',', '\t', ';':
+            if !quoted {
+                counts[c]++
+            }
+        }
+    }
+    delim, best := rune(0), 0
+    for _, c := range []rune{',', '\t', ';'} {
+        if counts[c] > best {
+            delim, best = c, counts[c]
+        }
+    }
+    switch delim {
+    case 0:
+        return WhitespaceFormat, 0
+    case '\t':
+        return TSVFormat, delim
+    }
+    return CSVFormat, delim
+}
+
+// recordReader returns the fields of each record of a text format and the line
+// it starts at, or io.EOF once there're no records left
+type recordReader func() ([]string, int, error)
+
+// delimitedRecords reads records of values separated by delim, which may be quoted
+func delimitedRecords(r io.Reader, delim rune) recordReader {
+    cr := csv.NewReader(r)
+    cr.Comma = delim
+    cr.FieldsPerRecord = -1
+    cr.TrimLeadingSpace = true
+    cr.ReuseRecord = true
+    return func() ([]string, int, error) {
+        items, err := cr.Read()
+        if err != nil {
+            return nil, 0, err
+        }
+        line, _ := cr.FieldPos(0)
+        return items, line, nil
+    }
+}
+
+// whitespaceRecords reads lines of values separated by spaces or tabs, skipping blank lines
+func whitespaceRecords(r *bufio.Reader) recordReader {
+    line := 0
+    return func() ([]string, int, error) {
+        for {
+            text, err := r.ReadString('\n')
+            if err != nil && (err != io.EOF || text == "") {
+                return nil, line + 1, err
+            }
+            line++
+            if items := strings.Fields(text); len(items) > 0 {
+                return items, line, nil
+            }
+        }
+    }
+}
+
+// jsonRecords reads lines of JSON arrays or objects, skipping blank lines.
+// Object keys are stored in names, being defined by the first object, and
+// values are returned as text, null and absent values being empty
+func jsonRecords(r *bufio.Reader, names *[]string) recordReader {
+    line := 0
+    var index map[string]int
+    return func() ([]string, int, error) {
+        for {
+            text, err := r.ReadString('\n')
+            if err != nil && (err != io.EOF || text == "") {
+                return nil, line + 1, err
+            }
+            line++
+            text = strings.TrimSpace(text)
+            if text == "" {
+                continue
+            }
+            if text[0] == '[' {
+                var values []interface{}
+                dec := json.NewDecoder(strings.NewReader(text))
+                dec.UseNumber()
+                if err := dec.Decode(&values); err != nil {
+                    return nil, line, &ParseError{line, 1, err.Error()}
+                }
+                items := make([]string, len(values))
+                for i, v := range values {
+                    items[i] = jsonText(v)
+                }
+                return items, line, nil
+            }
+            keys, values, err := jsonObject(text)
+            if err != nil {
+                return nil, line, &ParseError{line, 1, err.Error()}
+            }
+            if index == nil {
+                *names = keys
+                index = map[string]int{}
+                for i, k := range keys {
+                    index[k] = i
+                }
+            }
+            items := make([]string, len(index))
+            for i, k := range keys {
+                pos, ok := index[k]
+                if !ok {
+                    return nil, line, &ParseError{line, i + 1, fmt.Sprintf("unknown key '%s'", k)}
+                }
+                items[pos] = values[i]
+            }
+            return items, line, nil
+        }
+    }
+}
+
+// jsonObject returns the keys of a JSON object, in their order, and the text of their values
+func jsonObject(text string) ([]string, []string, error) {
+    dec := json.NewDecoder(strings.NewReader(text))
+    dec.UseNumber()
+    if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+        return nil, nil, fmt.Errorf("expected a JSON array or object")
+    }
+    keys, values := []string{}, []string{}
+    for dec.More() {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, nil, err
+        }
+        var v interface{}
+        if err := dec.Decode(&v); err != nil {
+            return nil, nil, err
+        }
+        keys = append(keys, tok.(string))
+        values = append(values, jsonText(v))
+    }
+    return keys, values, nil
+}
+
+// jsonText returns the text of a JSON value, null being empty
+func jsonText(v interface{}) string {
+    if v == nil {
+        return ""
+    }
+    return fmt.Sprint(v)
+}
+
+// WriteBinary writes the dataset in the binary columnar format, which is read
+// much faster than text formats. The file has the magic bytes "SRGPCOL2", the
+// number of columns (uint32) and rows (uint64), the flags (uint8), the name of each
+// column (uint16 length and bytes) and the values of each column, the output being
+// the last one. Weights are written as a column named 'weight' before the output,
+// setting the binaryWeighted flag so they're read back as weights. Numbers are little endian
+func WriteBinary(w io.Writer, ds *Dataset) error {
+    bw := bufio.NewWriter(w)
+    names := append([]string{}, ds.Variables...)
+    var flags uint8
+    if ds.Weights != nil {
+        names = append(names, "weight")
+        flags |= binaryWeighted
+    }
+    names = append(names, "y")
+    bw.WriteString(binaryMagic)
+    binary.Write(bw, binary.LittleEndian, uint32(len(names)))
+    binary.Write(bw, binary.LittleEndian, uint64(ds.Rows()))
+    binary.Write(bw, binary.LittleEndian, flags)
+    for _, name := range names {
+        binary.Write(bw, binary.LittleEndian, uint16(len(name)))
+        bw.WriteString(name)
+    }
+    if err := binary.Write(bw, binary.LittleEndian, ds.Input); err != nil {
+        return err
+    }
+    if ds.Weights != nil {
+        if err := binary.Write(bw, binary.LittleEndian, ds.Weights); err != nil {
+            return err
+        }
+    }
+    if err := binary.Write(bw, binary.LittleEndian, ds.Output); err != nil {
+        return err
+    }
+    return bw.Flush()
+}
+
+// readBinary reads the names and values of the columns of a file of the binary format,
+// reporting whether the column before the output has the weights of the rows
+func readBinary(r io.Reader) ([]string, [][]float64, bool, error) {
+    magic := make([]byte, len(binaryMagic))
+    if _, err := io.ReadFull(r, magic); err != nil || (string(magic) != binaryMagic && string(magic) != binaryMagicV1) {
+        return nil, nil, false, fmt.Errorf("not a binary dataset file")
+    }
+    var ncols uint32
+    var nrows uint64
+    var flags uint8
+    if err := binary.Read(r, binary.LittleEndian, &ncols); err != nil {
+        return nil, nil, false, err
+    }
+    if err := binary.Read(r, binary.LittleEndian, &nrows); err != nil {
+        return nil, nil, false, err
+    }
+    if string(magic) == binaryMagic {
+        if err := binary.Read(r, binary.LittleEndian, &flags); err != nil {
+            return nil, nil, false, err
+        }
+    }
+    weighted := flags&binaryWeighted != 0
+    if ncols < 2 || (weighted && ncols < 3) {
+        return nil, nil, false, fmt.Errorf("at least one input and one output column are needed")
+    }
+    // names are appended as they're read, so a corrupt number of columns
+    // fails at the end of the file instead of allocating them at once
+    names := []string{}
+    for i := uint32(0); i < ncols; i++ {
+        var size uint16
+        if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+            return nil, nil, false, err
+        }
+        name := make([]byte, size)
+        if _, err := io.ReadFull(r, name); err != nil {
+            return nil, nil, false, err
+        }
+        names = append(names, string(name))
+    }
+    // the number of rows isn't trusted until the first column is read in full,
+    // so a corrupt one fails at the end of the file instead of allocating its
+    // values at once. The columns are then read into a single buffer
+    first, err := readChunked(r, nrows)
+    if err != nil {
+        return nil, nil, false, fmt.Errorf("column 1: %w", err)
+    }
+    n := len(first)
+    buf := make([]float64, int(ncols) * n)
+    cols := make([][]float64, ncols)
+    for j := range cols {
+        cols[j] = buf[j*n : (j+1)*n]
+        if j == 0 {
+            copy(cols[j], first)
+            continue
+        }
+        if err := readValues(r, cols[j]); err != nil {
+            return nil, nil, false, fmt.Errorf("column %d: %w", j+1, err)
+        }
+    }
+    return names, cols, weighted, nil
+}
+
+// binaryChunk is the number of values read at a time, bounding the
+// temporary memory binary.Read needs to decode them
+const binaryChunk = 1 << 16
+
+// readValues fills values with the ones read from r
+func readValues(r io.Reader, values []float64) error {
+    for start := 0; start < len(values); start += binaryChunk {
+        end := start + binaryChunk
+        if end > len(values) {
+            end = len(values)
+        }
+        if err := binary.Read(r, binary.LittleEndian, values[start:end]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// readChunked reads n values, growing the slice as they're read
+func readChunked(r io.Reader, n uint64) ([]float64, error) {
+    col := []float64{}
+    buf := make([]float64, binaryChunk)
+    for left := n; left > 0; {
+        size := uint64(binaryChunk)
+        if left < size {
+            size = left
+        }
+        if err := readValues(r, buf[:size]); err != nil {
+            return nil, err
+        }
+        col = append(col, buf[:size]...)
+        left -= size
+    }
+    return col, nil
+}
+
+// dropMissing handles the missing and non-finite values of columns read from a
+// binary file with the given policy, removing the rows that are skipped
+func dropMissing(cols [][]float64, policy MissingPolicy, report *ReadReport) ([][]float64, error) {
+    out := cols[len(cols)-1]
+    keep := 0
+    for i := range out {
+        ok := true
+        for j, col := range cols {
+            if math.IsInf(col[i], 0) {
+                col[i] = math.NaN()
+            }
+            if !math.IsNaN(col[i]) {
+                continue
+            }
+            if policy == FailMissing {
+                return nil, fmt.Errorf("row %d, column %d: missing or non-finite value", i+1, j+1)
+            }
+            if policy == SkipMissing || j == len(cols)-1 {
+                ok = false
+            }
+        }
+        if !ok {
+            report.Skipped++
+            continue
+        }
+        for _, col := range cols {
+            col[keep] = col[i]
+        }
+        keep++
+    }
+    for j := range cols {
+        cols[j] = cols[j][:keep]
+    }
+    return cols, nil
+}
@@ -0,0 +1,88 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// binaryFile returns a binary dataset file with the given header and values
+func binaryFile(ncols uint32, nrows uint64, flags uint8, names []string, values []float64) *bytes.Reader {
+    var b bytes.Buffer
+    b.WriteString(binaryMagic)
+    binary.Write(&b, binary.LittleEndian, ncols)
+    binary.Write(&b, binary.LittleEndian, nrows)
+    binary.Write(&b, binary.LittleEndian, flags)
+    for _, name := range names {
+        binary.Write(&b, binary.LittleEndian, uint16(len(name)))
+        b.WriteString(name)
+    }
+    binary.Write(&b, binary.LittleEndian, values)
+    return bytes.NewReader(b.Bytes())
+}
+
+func TestReadBinaryRejectsInvalidHeaders(t *testing.T) {
+    tests := map[string]*bytes.Reader{
+        "no columns": binaryFile(0, 2, 0, nil, nil),
+        "no inputs": binaryFile(1, 2, 0, []string{"y"}, []float64{1, 2}),
+        "only weights": binaryFile(2, 2, binaryWeighted, []string{"weight", "y"}, []float64{1, 2, 3, 4}),
+        "corrupt rows": binaryFile(2, 1 << 60, 0, []string{"a", "y"}, []float64{1, 2, 3, 4}),
+        "corrupt columns": binaryFile(math.MaxUint32, 2, 0, []string{"a", "y"}, []float64{1, 2, 3, 4}),
+    }
+    for name, r := range tests {
+        if _, _, _, err := readBinary(r); err == nil {
+            t.Errorf("%s: expected an error", name)
+        }
+    }
+}
+
+func TestWriteBinaryRoundTrip(t *testing.T) {
+    ds := testDataset()
+    fpath := filepath.Join(t.TempDir(), "data.bin")
+    f, err := os.Create(fpath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := WriteBinary(f, ds); err != nil {
+        t.Fatal(err)
+    }
+    f.Close()
+    read, err := Read(fpath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(read.Variables, ds.Variables) || !reflect.DeepEqual(read.Input, ds.Input) || !reflect.DeepEqual(read.Output, ds.Output) {
+        t.Errorf("got %+v, want %+v", *read, *ds)
+    }
+    if !reflect.DeepEqual(read.Weights, ds.Weights) {
+        t.Errorf("got weights %v, want %v", read.Weights, ds.Weights)
+    }
+    if _, _, err := ReadWith(fpath, ReadOptions{ Missing: FailMissing, Weight: "a" }); err == nil {
+        t.Error("weights given to a file that has them didn't return an error")
+    }
+}
+
+// TestReadBinaryVersion1 checks files without flags are still read, a column
+// named 'weight' being an input
+func TestReadBinaryVersion1(t *testing.T) {
+    var b bytes.Buffer
+    b.WriteString(binaryMagicV1)
+    binary.Write(&b, binary.LittleEndian, uint32(2))
+    binary.Write(&b, binary.LittleEndian, uint64(2))
+    for _, name := range []string{"weight", "y"} {
+        binary.Write(&b, binary.LittleEndian, uint16(len(name)))
+        b.WriteString(name)
+    }
+    binary.Write(&b, binary.LittleEndian, []float64{1, 2, 3, 4})
+    names, cols, weighted, err := readBinary(&b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if weighted || !reflect.DeepEqual(names, []string{"weight", "y"}) || !reflect.DeepEqual(cols, [][]float64{{1, 2}, {3, 4}}) {
+        t.Errorf("got columns %v %v, weighted %v", names, cols, weighted)
+    }
+}
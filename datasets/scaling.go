@@ -0,0 +1,98 @@
+package dataset
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scaler is a linear transform of the input columns and, optionally, of the
+// output of a dataset, mapping each value v to (v - Offset) / Scale
+type Scaler struct {
+    Method       string
+    InputOffset  []float64
+    InputScale   []float64
+    OutputOffset float64
+    OutputScale  float64
+}
+
+// FitScaler returns the scaler of the given method fitted on the dataset.
+// 'minmax' maps the values of each column to [0, 1] and 'zscore' to zero mean
+// and unit standard deviation. The output is only scaled if target is true
+func FitScaler(ds *Dataset, method string, target bool) (*Scaler, error) {
+    var fit func(vals []float64) (float64, float64)
+    switch method {
+    case "minmax":
+        fit = minMax
+    case "zscore":
+        fit = zScore
+    default:
+        return nil, fmt.Errorf("unknown scaling method '%s'", method)
+    }
+    if ds.Rows() == 0 {
+        return nil, fmt.Errorf("can't fit a scaler on an empty dataset")
+    }
+
+    s := &Scaler{
+        Method: method,
+        InputOffset: make([]float64, len(ds.Variables)),
+        InputScale: make([]float64, len(ds.Variables)),
+        OutputScale: 1.0,
+    }
+    for j := range s.InputOffset {
+        s.InputOffset[j], s.InputScale[j] = fit(ds.Column(j))
+    }
+    if target {
+        s.OutputOffset, s.OutputScale = fit(ds.Output)
+    }
+    return s, nil
+}
+
+// minMax returns the offset and scale mapping the values to [0, 1]
+func minMax(vals []float64) (float64, float64) {
+    min, max := math.Inf(1), math.Inf(-1)
+    for _, v := range vals {
+        min = math.Min(min, v)
+        max = math.Max(max, v)
+    }
+    return min, nonZeroScale(max - min)
+}
+
+// zScore returns the offset and scale mapping the values to zero mean and unit standard deviation
+func zScore(vals []float64) (float64, float64) {
+    var mean, sq float64
+    for _, v := range vals {
+        mean += v
+    }
+    mean /= float64(len(vals))
+    for _, v := range vals {
+        sq += (v - mean) * (v - mean)
+    }
+    return mean, nonZeroScale(math.Sqrt(sq / float64(len(vals))))
+}
+
+// nonZeroScale keeps constant columns unscaled, only shifting them
+func nonZeroScale(scale float64) float64 {
+    if scale < 1e-10 {
+        return 1.0
+    }
+    return scale
+}
+
+// Transform returns a scaled copy of the dataset, sharing its weights
+func (s *Scaler) Transform(ds *Dataset) *Dataset {
+    out := &Dataset{
+        Input: make([]float64, len(ds.Input)),
+        Output: make([]float64, len(ds.Output)),
+        Variables: append([]string{}, ds.Variables...),
+        Weights: ds.Weights,
+    }
+    n := ds.Rows()
+    for i, v := range ds.Input {
+        j := i / n
+        out.Input[i] = (v - s.InputOffset[j]) / s.InputScale[j]
+    }
+    for i, v := range ds.Output {
+        out.Output[i] = (v - s.OutputOffset) / s.OutputScale
+    }
+    return out
+}
@@ -0,0 +1,106 @@
+package dataset
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Subset returns a copy of the rows of the given indices, in their order
+func (ds *Dataset) Subset(indices []int) *Dataset {
+    n := ds.Rows()
+    out := &Dataset{
+        Input: make([]float64, 0, len(indices)*len(ds.Variables)),
+        Output: make([]float64, len(indices)),
+        Variables: append([]string{}, ds.Variables...),
+    }
+    for j := range ds.Variables {
+        col := ds.Input[j*n : (j+1)*n]
+        for _, idx := range indices {
+            out.Input = append(out.Input, col[idx])
+        }
+    }
+    for i, idx := range indices {
+        out.Output[i] = ds.Output[idx]
+    }
+    if ds.Weights != nil {
+        out.Weights = make([]float64, len(indices))
+        for i, idx := range indices {
+            out.Weights[i] = ds.Weights[idx]
+        }
+    }
+    return out
+}
+
+// Columns returns a dataset with copies of the input columns of the given variable
+// names, in their order, sharing the output and weights with ds. Variables are indexed by their new positions
+func (ds *Dataset) Columns(names []string) (*Dataset, error) {
+    idx := make([]int, len(names))
+    for i, name := range names {
+        idx[i] = -1
+        for j, v := range ds.Variables {
+            if v == name {
+                idx[i] = j
+                break
+            }
+        }
+        if idx[i] < 0 {
+            return nil, fmt.Errorf("unknown variable '%s'", name)
+        }
+    }
+    out := &Dataset{
+        Input: make([]float64, 0, len(idx)*ds.Rows()),
+        Output: ds.Output,
+        Variables: append([]string{}, names...),
+        Weights: ds.Weights,
+    }
+    for _, j := range idx {
+        out.Input = append(out.Input, ds.Column(j)...)
+    }
+    return out, nil
+}
+
+// Shuffle returns a copy of the rows in an order defined by rng
+func (ds *Dataset) Shuffle(rng *rand.Rand) *Dataset {
+    return ds.Subset(rng.Perm(ds.Rows()))
+}
+
+// Sample returns a copy of n rows drawn with rng without replacement, or of every row
+// if there're less than n
+func (ds *Dataset) Sample(n int, rng *rand.Rand) *Dataset {
+    perm := rng.Perm(ds.Rows())
+    if n < len(perm) {
+        perm = perm[:n]
+    }
+    return ds.Subset(perm)
+}
+
+// Split shuffles the rows with rng and splits them into training, validation and
+// test datasets, the last two having the given fractions of the rows.
+// Validation and test datasets with no rows are nil
+func (ds *Dataset) Split(rng *rand.Rand, valFrac, testFrac float64) (train, val, test *Dataset) {
+    perm := rng.Perm(ds.Rows())
+    nval := int(valFrac * float64(len(perm)))
+    ntest := int(testFrac * float64(len(perm)))
+    if nval > 0 {
+        val = ds.Subset(perm[:nval])
+    }
+    if ntest > 0 {
+        test = ds.Subset(perm[nval:nval+ntest])
+    }
+    return ds.Subset(perm[nval+ntest:]), val, test
+}
+
+// KFold shuffles the rows with rng and splits them into k folds of about the
+// same size, returning for each fold the training dataset with the rows of the
+// other folds and the held-out dataset with the rows of the fold
+func (ds *Dataset) KFold(rng *rand.Rand, k int) (train, heldout []*Dataset) {
+    perm := rng.Perm(ds.Rows())
+    for f := 0; f < k; f++ {
+        start := f * len(perm) / k
+        end := (f + 1) * len(perm) / k
+        rest := append(append([]int{}, perm[:start]...), perm[end:]...)
+        train = append(train, ds.Subset(rest))
+        heldout = append(heldout, ds.Subset(perm[start:end]))
+    }
+    return train, heldout
+}
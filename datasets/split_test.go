@@ -0,0 +1,86 @@
+package dataset
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testDataset returns a weighted dataset of 5 rows, where a = i, b = 10 * i,
+// y = 100 * i and the weight is i + 1 on row i
+func testDataset() *Dataset {
+    return &Dataset{
+        Input: []float64{0, 1, 2, 3, 4, 0, 10, 20, 30, 40},
+        Output: []float64{0, 100, 200, 300, 400},
+        Variables: []string{"a", "b"},
+        Weights: []float64{1, 2, 3, 4, 5},
+    }
+}
+
+func TestCopyIsDeep(t *testing.T) {
+    ds := testDataset()
+    cp := ds.Copy()
+    cp.Input[0] = -1
+    cp.Output[0] = -1
+    cp.Weights[0] = -1
+    cp.Variables[0] = "changed"
+    if !reflect.DeepEqual(ds, testDataset()) {
+        t.Errorf("changing the copy changed the original: %+v", *ds)
+    }
+}
+
+func TestSubsetKeepsOrder(t *testing.T) {
+    sub := testDataset().Subset([]int{3, 0, 4})
+    want := &Dataset{
+        Input: []float64{3, 0, 4, 30, 0, 40},
+        Output: []float64{300, 0, 400},
+        Variables: []string{"a", "b"},
+        Weights: []float64{4, 1, 5},
+    }
+    if !reflect.DeepEqual(sub, want) {
+        t.Errorf("got %+v, want %+v", *sub, *want)
+    }
+}
+
+func TestColumns(t *testing.T) {
+    ds := testDataset()
+    cols, err := ds.Columns([]string{"b"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := []float64{0, 10, 20, 30, 40}; !reflect.DeepEqual(cols.Input, want) {
+        t.Errorf("got input %v, want %v", cols.Input, want)
+    }
+    if _, err := ds.Columns([]string{"a", "c"}); err == nil {
+        t.Error("unknown column name didn't return an error")
+    }
+}
+
+func TestShuffleIsReproducible(t *testing.T) {
+    ds := testDataset()
+    a := ds.Shuffle(rand.New(rand.NewSource(7)))
+    b := ds.Shuffle(rand.New(rand.NewSource(7)))
+    if !reflect.DeepEqual(a, b) {
+        t.Errorf("same seed gave different orders: %v and %v", a.Output, b.Output)
+    }
+    // rows are kept whole
+    for i, y := range a.Output {
+        if r := int(y / 100); a.Input[i] != float64(r) || a.Weights[i] != float64(r + 1) {
+            t.Errorf("row %d has values of different rows", i)
+        }
+    }
+}
+
+func TestSampleMoreThanRows(t *testing.T) {
+    ds := testDataset()
+    s := ds.Sample(ds.Rows() + 10, rand.New(rand.NewSource(1)))
+    if s.Rows() != ds.Rows() {
+        t.Fatalf("got %d rows, want %d", s.Rows(), ds.Rows())
+    }
+    out := append([]float64{}, s.Output...)
+    sort.Float64s(out)
+    if !reflect.DeepEqual(out, ds.Output) {
+        t.Errorf("got rows %v, want every row once", s.Output)
+    }
+}
@@ -0,0 +1,218 @@
+package experiment
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/franciscobonand/symb-regr-gp/datasets"
+	"github.com/franciscobonand/symb-regr-gp/operator"
+	pop "github.com/franciscobonand/symb-regr-gp/population"
+	"github.com/franciscobonand/symb-regr-gp/stats"
+)
+
+// CountClasses returns the number of classes of a classification problem, whose
+// outputs must be labels from 0 to the number of classes - 1. The labels of the
+// validation and test datasets must be classes of the training dataset
+func CountClasses(data Data) (int, error) {
+    classes := 0
+    for i, y := range data.Train.Output {
+        if y < 0 || y != math.Trunc(y) {
+            return 0, fmt.Errorf("row %d of the training data: class label %g must be an integer of at least 0", i+1, y)
+        }
+        if int(y) + 1 > classes {
+            classes = int(y) + 1
+        }
+    }
+    if classes < 2 {
+        return 0, fmt.Errorf("classification needs at least two classes")
+    }
+    for _, ds := range []*dataset.Dataset{data.Validation, data.Test} {
+        if ds == nil {
+            continue
+        }
+        for i, y := range ds.Output {
+            if y < 0 || y != math.Trunc(y) || int(y) >= classes {
+                return 0, fmt.Errorf("row %d of the validation or test data: class label %g isn't a class of the training data", i+1, y)
+            }
+        }
+    }
+    return classes, nil
+}
+
+// relabel returns a view of ds whose output is 1 for rows of the given class and 0 for the others
+func relabel(ds *dataset.Dataset, class int) *dataset.Dataset {
+    if ds == nil {
+        return nil
+    }
+    out := *ds
+    out.Output = make([]float64, len(ds.Output))
+    for i, y := range ds.Output {
+        if int(y) == class {
+            out.Output[i] = 1
+        }
+    }
+    return &out
+}
+
+// runOneVsRest executes a binary classification run per class, telling the
+// class from the others, and combines their best trees into a tree that
+// predicts the class whose tree has the greatest output. Its log-loss uses the
+// softmax of the outputs of the trees as the probabilities of the classes.
+// The hook is called with the rows of the run of each class in turn, the
+// generations of class c being offset by c * (cfg.Generations + 1) so the rows
+// of a run are still distinct and in order, while the rows of the result merge
+// the ones of every class into a row per generation.
+// The hall of fame has the combinations of the i-th members of the classes.
+// The runs of the classes draw their random numbers from rng in turn
+func runOneVsRest(cfg Config, d Data, run, seed int64, rng *rand.Rand, hook GenerationHook) (Result, error) {
+    binary := cfg
+    binary.Classes = 2
+    res := Result{ Run: run, Seed: seed }
+    classRows := make([][]stats.Row, cfg.Classes)
+    best := make([]operator.Expr, cfg.Classes)
+    hofs := make([][]pop.Member, cfg.Classes)
+    for c := range best {
+        cd := Data{ Train: relabel(d.Train, c), Validation: relabel(d.Validation, c) }
+        classHook := hook
+        if hook != nil {
+            offset := c * (cfg.Generations + 1)
+            classHook = func(row stats.Row, p pop.Population, ds *dataset.Dataset) {
+                row.Gen += offset
+                hook(row, p, ds)
+            }
+        }
+        r, err := evolve(binary, cd, run, seed, rng, classHook)
+        if err != nil {
+            return Result{}, err
+        }
+        classRows[c], best[c], hofs[c] = r.Rows, r.Best.Expr(), r.HallOfFame
+    }
+    res.Rows = mergeRows(classRows)
+
+    for i := 0; i < cfg.HofSize; i++ {
+        trees := make([]operator.Expr, cfg.Classes)
+        gen := 0
+        for c, members := range hofs {
+            if i >= len(members) {
+                trees = nil
+                break
+            }
+            trees[c] = members[i].Expr()
+            if members[i].Generation > gen {
+                gen = members[i].Generation
+            }
+        }
+        if trees == nil {
+            break
+        }
+        res.HallOfFame = append(res.HallOfFame, pop.Member{ Individual: oneVsRest(cfg, trees, d.Train), Generation: gen })
+    }
+    train := NewEvaluator(cfg, d.Train)
+    sort.SliceStable(res.HallOfFame, func(i, j int) bool {
+        return train.CompareFitness(res.HallOfFame[i].Fitness, res.HallOfFame[j].Fitness)
+    })
+
+    // the best tree of each class was already chosen on the validation data of the class
+    res.Best = oneVsRest(cfg, best, d.Train)
+    res.ValidationFitness, res.TestFitness = math.NaN(), math.NaN()
+    if d.Validation != nil {
+        veval := NewEvaluator(cfg, d.Validation)
+        res.ValidationFitness = oneVsRestFitness(cfg, best, d.Validation)
+        for _, m := range res.HallOfFame {
+            if fit := oneVsRestFitness(cfg, classTrees(m.Expr()), d.Validation); veval.CompareFitness(fit, res.ValidationFitness) {
+                res.Best, res.ValidationFitness = m.Individual, fit
+            }
+        }
+    }
+    if d.Test != nil {
+        res.TestFitness = oneVsRestFitness(cfg, classTrees(res.Best.Expr()), d.Test)
+        res.TestConfusion = pop.ConfusionMatrix(res.Best.Predict(d.Test), d.Test, cfg.Classes)
+    }
+    return res, nil
+}
+
+// oneVsRest returns the individual that combines the trees of each class, evaluated on ds
+func oneVsRest(cfg Config, trees []operator.Expr, ds *dataset.Dataset) *pop.Individual {
+    ind := pop.Create(pop.OneVsRest(trees))
+    ind.Fitness = oneVsRestFitness(cfg, trees, ds)
+    ind.FitnessValid = !math.IsNaN(ind.Fitness)
+    return ind
+}
+
+// classTrees returns the trees of each class combined by pop.OneVsRest into code
+func classTrees(code operator.Expr) []operator.Expr {
+    trees := []operator.Expr{}
+    for _, pos := range code.Children(0) {
+        trees = append(trees, code.Subtree(pos))
+    }
+    return trees
+}
+
+// mergeRows merges the stats of the runs of each class into a row per generation.
+// Evaluations and crossover children are summed, fitness and size stats are the
+// mean of the classes, except for the extreme sizes, and the best individual
+// combines the best ones of the classes
+func mergeRows(classRows [][]stats.Row) []stats.Row {
+    rows := append([]stats.Row{}, classRows[0]...)
+    n := float64(len(classRows))
+    for g := range rows {
+        r := &rows[g]
+        best := []string{r.Best}
+        for _, other := range classRows[1:] {
+            o := other[g]
+            r.Evals += o.Evals
+            r.RowEvals += o.RowEvals
+            r.NodeEvals += o.NodeEvals
+            r.Repeated += o.Repeated
+            r.BestFit += o.BestFit
+            r.WorstFit += o.WorstFit
+            r.MeanFit += o.MeanFit
+            r.MaxSize = math.Max(r.MaxSize, o.MaxSize)
+            r.MinSize = math.Min(r.MinSize, o.MinSize)
+            r.MeanSize += o.MeanSize
+            r.BetterCxChild += o.BetterCxChild
+            r.WorseCxChild += o.WorseCxChild
+            best = append(best, o.Best)
+        }
+        r.BestFit /= n
+        r.WorstFit /= n
+        r.MeanFit /= n
+        r.MeanSize /= n
+        r.Best = "argmax(" + strings.Join(best, ", ") + ")"
+    }
+    return rows
+}
+
+// oneVsRestFitness returns the fitness on ds of the combination of the trees
+// of each class, or NaN if it's invalid
+func oneVsRestFitness(cfg Config, trees []operator.Expr, ds *dataset.Dataset) float64 {
+    if cfg.Metric != pop.LogLossMetric {
+        return fitnessOn(cfg, pop.Create(pop.OneVsRest(trees)), ds)
+    }
+    outputs := make([][]float64, len(trees))
+    for c, tree := range trees {
+        outputs[c] = tree.EvalColumns(ds.InputColumns(), ds.Rows())
+    }
+    var acc, total float64
+    for i, y := range ds.Output {
+        // log softmax, shifted by the greatest output so exp doesn't overflow
+        max := math.Inf(-1)
+        for c := range outputs {
+            max = math.Max(max, outputs[c][i])
+        }
+        var sum float64
+        for c := range outputs {
+            sum += math.Exp(outputs[c][i] - max)
+        }
+        p := math.Exp(outputs[int(y)][i] - max) / sum
+        acc -= ds.Weight(i) * math.Log(math.Max(p, 1e-15))
+        total += ds.Weight(i)
+    }
+    if math.IsNaN(acc) || math.IsInf(acc, 0) || total <= 0 {
+        return math.NaN()
+    }
+    return acc / total
+}
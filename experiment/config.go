@@ -0,0 +1,211 @@
+package experiment
+
+import (
+	"errors"
+
+	pop "github.com/franciscobonand/symb-regr-gp/population"
+)
+
+// Config holds the parameters of a GP run
+type Config struct {
+    PopSize           int
+    Generations       int
+    Threads           int
+    Elitism           int
+    // Selector is the name of the selection method, and the fields below it its parameters
+    Selector          string
+    TournamentSize    int
+    RouletteTransform string
+    RankPressure      float64
+    RankBase          float64
+    Temperature       float64
+    Cooling           float64
+    ParsimonySize     float64
+    // Crossover is the name of the crossover operator, and Mutation a comma
+    // separated list of mutation operators, each optionally weighted as 'name:weight'
+    Crossover         string
+    Mutation          string
+    CrossProb         float64
+    MutProb           float64
+    SemEps            float64
+    MutSigma          float64
+    GSGPStep          float64
+    ERC               float64
+    // Acceptance is the name of the acceptance policy of variation children
+    Acceptance        string
+    AnnealTemp        float64
+    AnnealCooling     float64
+    AccProb           float64
+    HofSize           int
+    // Scale is the scaling method of the data ('none', 'minmax' or 'zscore'),
+    // fitted on the training data. ScaleTarget tells whether the output is also scaled
+    Scale             string
+    ScaleTarget       bool
+    // Sampling is the technique of evaluating the individuals on a sample of the
+    // training rows drawn every generation ('none', 'random' or 'interleaved'),
+    // and SampleSize the number of rows of each sample
+    Sampling          string
+    SampleSize        int
+    // Task is 'regression' or 'classification'. Classification trees are
+    // evaluated with Metric, and problems of more than two classes are solved by a
+    // single tree with ordered classes ('ordinal') or a tree per class ('ovr').
+    // Classes is the number of classes, which is defined by the data
+    Task              string
+    Metric            string
+    Multiclass        string
+    Classes           int
+}
+
+var fitnessTransforms = map[string]pop.FitnessTransform{
+    "window": pop.WindowTransform,
+    "inverse": pop.InverseTransform,
+    "rank": pop.RankTransform,
+}
+
+var validCrossovers = map[string]bool{
+    "subtree": true,
+    "sizefair": true,
+    "homologous": true,
+    "onepoint": true,
+    "uniform": true,
+    "semantic": true,
+    "gsgp": true,
+}
+
+var validAcceptances = map[string]bool{
+    "greedy": true,
+    "always": true,
+    "anneal": true,
+    "tournament": true,
+}
+
+var validScalings = map[string]bool{
+    "none": true,
+    "minmax": true,
+    "zscore": true,
+}
+
+var validSamplings = map[string]bool{
+    "none": true,
+    "random": true,
+    "interleaved": true,
+}
+
+var validMetrics = map[string]bool{
+    pop.AccuracyMetric: true,
+    pop.BalancedAccuracyMetric: true,
+    pop.LogLossMetric: true,
+    pop.F1Metric: true,
+}
+
+var validSelectors = map[string]bool{
+    "rol": true,
+    "tour": true,
+    "lex": true,
+    "rank": true,
+    "exprank": true,
+    "sus": true,
+    "boltz": true,
+    "dtour": true,
+    "rand": true,
+}
+
+// Validate returns an error describing the first invalid parameter of the configuration
+func (c Config) Validate() error {
+    if c.PopSize <= 0 || c.Threads <= 0 || c.Generations <= 0 {
+        return errors.New("Invalid value for popsize, gens or threads, must be a positive integer")
+    }
+    if c.Elitism < 0 {
+        return errors.New("Elitism size must be at least 0")
+    }
+    if !validSelectors[c.Selector] {
+        return errors.New("Invalid selector, must be 'rol', 'tour', 'lex', 'rank', 'exprank', 'sus', 'boltz', 'dtour' or 'rand'")
+    }
+    if (c.Selector == "tour" || c.Selector == "dtour") && c.TournamentSize < 2 {
+        return errors.New("Tournament size must be at least 2")
+    }
+    if _, ok := fitnessTransforms[c.RouletteTransform]; !ok {
+        return errors.New("Invalid roulette fitness transform, must be 'window', 'inverse' or 'rank'")
+    }
+    if c.Selector == "rank" && (c.RankPressure < 1.0 || c.RankPressure > 2.0) {
+        return errors.New("Rank selective pressure must be between 1.0 and 2.0")
+    }
+    if c.Selector == "exprank" && (c.RankBase <= 0.0 || c.RankBase >= 1.0) {
+        return errors.New("Exponential rank base must be between 0.0 and 1.0 (exclusive)")
+    }
+    if c.Selector == "boltz" && (c.Temperature <= 0.0 || c.Cooling <= 0.0 || c.Cooling > 1.0) {
+        return errors.New("Boltzmann temperature must be positive and cooling rate between 0.0 (exclusive) and 1.0")
+    }
+    if c.Selector == "dtour" && (c.ParsimonySize < 1.0 || c.ParsimonySize > 2.0) {
+        return errors.New("Parsimony tournament size must be between 1.0 and 2.0")
+    }
+    if c.CrossProb < 0.0 || c.MutProb < 0.0 || c.CrossProb > 1.0 || c.MutProb > 1.0 {
+        return errors.New("Genetic operators probability must be between 0.0 and 1.0")
+    }
+    if !validCrossovers[c.Crossover] {
+        return errors.New("Invalid crossover operator, must be 'subtree', 'sizefair', 'homologous', 'onepoint', 'uniform', 'semantic' or 'gsgp'")
+    }
+    // geometric children have no tree for other operators to work on
+    if (c.Crossover == "gsgp" || c.usesMutation("gsgp")) && (c.Crossover != "gsgp" || c.Mutation != "gsgp") {
+        return errors.New("Geometric semantic crossover and mutation ('gsgp') must be used together and with no other operators")
+    }
+    if c.Crossover == "gsgp" && c.Selector == "lex" {
+        return errors.New("Geometric semantic operators can't be used with lexicase selection")
+    }
+    if !validAcceptances[c.Acceptance] {
+        return errors.New("Invalid acceptance policy, must be 'greedy', 'always', 'anneal' or 'tournament'")
+    }
+    if c.Acceptance == "anneal" && (c.AnnealTemp <= 0.0 || c.AnnealCooling <= 0.0 || c.AnnealCooling > 1.0) {
+        return errors.New("Annealing temperature must be positive and cooling rate between 0.0 (exclusive) and 1.0")
+    }
+    if c.AccProb < 0.0 || c.AccProb > 1.0 {
+        return errors.New("Tournament acceptance probability must be between 0.0 and 1.0")
+    }
+    if c.HofSize < 0 {
+        return errors.New("Hall of fame size must be at least 0")
+    }
+    if c.ERC < 0.0 || c.MutSigma < 0.0 {
+        return errors.New("Ephemeral constants range and gaussian mutation sigma must be at least 0.0")
+    }
+    // without ephemeral constants, trees have no constants to mutate
+    if c.usesMutation("gauss") && c.ERC <= 0.0 {
+        return errors.New("Gaussian mutation ('gauss') needs ephemeral random constants, the constants range must be positive")
+    }
+    if !validScalings[c.Scale] {
+        return errors.New("Invalid scaling method, must be 'none', 'minmax' or 'zscore'")
+    }
+    if c.Scale != "none" && c.Crossover == "gsgp" {
+        return errors.New("Scaling can't be used with geometric semantic operators, whose trees are too big to be unscaled")
+    }
+    if !validSamplings[c.Sampling] {
+        return errors.New("Invalid sampling technique, must be 'none', 'random' or 'interleaved'")
+    }
+    if c.Sampling != "none" && c.SampleSize <= 0 {
+        return errors.New("Sample size must be a positive integer")
+    }
+    if c.Sampling != "none" && (c.Crossover == "gsgp" || c.Selector == "lex") {
+        return errors.New("Sampling can't be used with geometric semantic operators or lexicase selection, which use every training row")
+    }
+    if c.Task != "regression" && c.Task != "classification" {
+        return errors.New("Invalid task, must be 'regression' or 'classification'")
+    }
+    if c.Task == "classification" && !validMetrics[c.Metric] {
+        return errors.New("Invalid classification metric, must be 'accuracy', 'balanced', 'logloss' or 'f1'")
+    }
+    if c.Task == "classification" && c.Multiclass != "ordinal" && c.Multiclass != "ovr" {
+        return errors.New("Invalid multiclass strategy, must be 'ordinal' or 'ovr'")
+    }
+    _, _, err := parseMutationSpec(c.Mutation)
+    return err
+}
+
+// usesMutation reports whether the mutation operator of the given name is one of the configuration's
+func (c Config) usesMutation(name string) bool {
+    names, _, _ := parseMutationSpec(c.Mutation)
+    for _, n := range names {
+        if n == name {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,46 @@
+package experiment
+
+import "testing"
+
+func TestValidateGeometricOperators(t *testing.T) {
+    tests := []struct {
+        crossover, mutation string
+        valid               bool
+    }{
+        {"gsgp", "gsgp", true},
+        {"subtree", "subtree,point", true},
+        {"gsgp", "subtree", false},
+        {"subtree", "gsgp", false},
+        {"subtree", "subtree,gsgp", false},
+        {"gsgp", "gsgp,point", false},
+        {"gsgp", "gsgp:2", false},
+    }
+    for _, tt := range tests {
+        cfg := testConfig()
+        cfg.Crossover, cfg.Mutation = tt.crossover, tt.mutation
+        if err := cfg.Validate(); (err == nil) != tt.valid {
+            t.Errorf("crossover '%s' and mutation '%s': got error %v, want valid %v", tt.crossover, tt.mutation, err, tt.valid)
+        }
+    }
+}
+
+func TestValidateGaussianMutationNeedsConstants(t *testing.T) {
+    tests := []struct {
+        mutation string
+        erc      float64
+        valid    bool
+    }{
+        {"gauss", 1, true},
+        {"subtree,gauss:0.5", 2, true},
+        {"subtree", 0, true},
+        {"gauss", 0, false},
+        {"subtree,gauss:0.5", 0, false},
+    }
+    for _, tt := range tests {
+        cfg := testConfig()
+        cfg.Mutation, cfg.ERC = tt.mutation, tt.erc
+        if err := cfg.Validate(); (err == nil) != tt.valid {
+            t.Errorf("mutation '%s' and erc %g: got error %v, want valid %v", tt.mutation, tt.erc, err, tt.valid)
+        }
+    }
+}
@@ -0,0 +1,484 @@
+package experiment
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/franciscobonand/symb-regr-gp/datasets"
+	"github.com/franciscobonand/symb-regr-gp/operator"
+	pop "github.com/franciscobonand/symb-regr-gp/population"
+	"github.com/franciscobonand/symb-regr-gp/stats"
+)
+
+// Result holds the outcome of a run
+type Result struct {
+    Run  int64
+    Seed int64
+    // Rows has the stats of every generation of the run
+    Rows []stats.Row
+    // Best is the best individual of the last generation or, if there's a
+    // validation dataset, the best hall of fame member on it
+    Best *pop.Individual
+    // ValidationFitness and TestFitness are the fitness of Best on the
+    // validation and test datasets, or NaN if there're none
+    ValidationFitness float64
+    TestFitness       float64
+    HallOfFame        []pop.Member
+    // TestConfusion is the confusion matrix of Best on the test dataset of
+    // classification runs, or nil if there's none
+    TestConfusion     [][]float64
+}
+
+// Data holds the datasets of a run. Validation and Test may be nil
+type Data struct {
+    Train      *dataset.Dataset
+    Validation *dataset.Dataset
+    Test       *dataset.Dataset
+}
+
+// Summary returns the final results of the run
+func (r Result) Summary() stats.Summary {
+    return stats.Summary{
+        Run: r.Run,
+        Seed: r.Seed,
+        TrainFit: r.Best.Fitness,
+        ValFit: r.ValidationFitness,
+        TestFit: r.TestFitness,
+        Size: r.Best.Size(),
+        Best: r.Best.Format(),
+    }
+}
+
+// GenerationHook is called at the end of every generation of a run with its
+// stats, population and the dataset the population is evaluated on, which is
+// scaled if the run scales the data
+type GenerationHook func(row stats.Row, p pop.Population, ds *dataset.Dataset)
+
+// Hooks are called while the runs are executed. Calls are serialized, so they
+// don't need to be safe for concurrent use, but runs executed in parallel call
+// them in no particular order. Nil hooks are ignored
+type Hooks struct {
+    Generation GenerationHook
+    // Done is called with the result of each run once it ends
+    Done func(r Result)
+}
+
+// NewEvaluator returns the fitness evaluator of the runs of the configuration on ds
+func NewEvaluator(cfg Config, ds *dataset.Dataset) pop.Evaluator {
+    if cfg.Task == "classification" {
+        return pop.ClassificationEvaluator(ds, cfg.Classes, cfg.Metric)
+    }
+    return pop.RMSE{ DS: ds }
+}
+
+// SetSeed sets the given number as seed of the global random number generator, which
+// is only used outside the runs, or a random value if seed is <= 0. It returns the seed set
+func SetSeed(seed int64) int64 {
+    if seed <= 0 {
+        max := big.NewInt(2<<31 - 1)
+        rseed, _ := crand.Int(crand.Reader, max)
+        seed = rseed.Int64()
+    }
+    rand.Seed(seed)
+    return seed
+}
+
+// RunAll executes independent GP runs, up to parallel of them at the same time,
+// and returns their results in run order.
+// Each run has its own random number generator seeded with seed+run, which is
+// recorded in its result, so it can be reproduced on its own whether runs are
+// parallel or not. If seed is <= 0, a random one is used
+func RunAll(cfg Config, data Data, runs, parallel int, seed int64, hooks Hooks) ([]Result, error) {
+    folds := make([]Data, runs)
+    for i := range folds {
+        folds[i] = data
+    }
+    return CrossValidate(cfg, folds, parallel, seed, hooks)
+}
+
+// CrossValidate executes a GP run on each fold, the run on folds[i] having id i,
+// the test dataset of each fold usually being its held-out rows.
+// Runs are executed as in RunAll
+func CrossValidate(cfg Config, folds []Data, parallel int, seed int64, hooks Hooks) ([]Result, error) {
+    results := make([]Result, len(folds))
+    errs := make([]error, len(folds))
+
+    var mu sync.Mutex
+    var hook GenerationHook
+    if hooks.Generation != nil {
+        hook = func(row stats.Row, p pop.Population, ds *dataset.Dataset) {
+            mu.Lock()
+            defer mu.Unlock()
+            hooks.Generation(row, p, ds)
+        }
+    }
+    seed = SetSeed(seed)
+    execute := func(run int64) {
+        results[run], errs[run] = Run(cfg, folds[run], run, seed + run, hook)
+        if errs[run] == nil && hooks.Done != nil {
+            mu.Lock()
+            defer mu.Unlock()
+            hooks.Done(results[run])
+        }
+    }
+
+    if parallel <= 1 {
+        for run := range folds {
+            execute(int64(run))
+            if errs[run] != nil {
+                return nil, errs[run]
+            }
+        }
+        return results, nil
+    }
+
+    var wg sync.WaitGroup
+    slots := make(chan struct{}, parallel)
+    for run := range folds {
+        wg.Add(1)
+        slots <- struct{}{}
+        go func(run int64) {
+            defer wg.Done()
+            execute(run)
+            <-slots
+        }(int64(run))
+    }
+    wg.Wait()
+    for _, err := range errs {
+        if err != nil {
+            return nil, err
+        }
+    }
+    return results, nil
+}
+
+// Run executes a GP run on the train dataset, evaluating its best individual on
+// the validation and test datasets if they aren't nil. Its random numbers are drawn from a generator
+// of its own seeded with seed, so runs with the same seed are identical. hook, if not nil, is called
+// at the end of every generation.
+// If the configuration scales the data, the stats are in scaled units, but the
+// individuals of the result are unscaled and evaluated in the original units.
+// If it samples the training rows, the stats are on the sample of each generation,
+// but the final population and hall of fame are evaluated again on every row
+func Run(cfg Config, d Data, run, seed int64, hook GenerationHook) (Result, error) {
+    rng := pop.NewRand(seed)
+    if cfg.Task == "classification" && cfg.Multiclass == "ovr" && cfg.Classes > 2 {
+        return runOneVsRest(cfg, d, run, seed, rng, hook)
+    }
+    return evolve(cfg, d, run, seed, rng, hook)
+}
+
+// evolve executes the GP run of Run drawing the random numbers from rng
+func evolve(cfg Config, d Data, run, seed int64, rng *rand.Rand, hook GenerationHook) (Result, error) {
+    train := d.Train
+    var scaler *dataset.Scaler
+    data := train
+    if cfg.Scale != "none" {
+        var err error
+        // class labels are never scaled
+        target := cfg.ScaleTarget && cfg.Task != "classification"
+        if scaler, err = dataset.FitScaler(train, cfg.Scale, target); err != nil {
+            return Result{}, err
+        }
+        data = scaler.Transform(train)
+    }
+
+    opset := newOpSet(cfg, data.Variables)
+    gen := pop.NewRampedGenerator(opset, 1, 6, rng)
+    counter := &pop.EvalCounter{}
+    newEval := func(ds *dataset.Dataset) pop.Evaluator {
+        return NewEvaluator(cfg, ds)
+    }
+    var sampler pop.Evaluator
+    switch cfg.Sampling {
+    case "random":
+        sampler = pop.RandomSamplingEvaluator(newEval, data, cfg.SampleSize, rng)
+    case "interleaved":
+        sampler = pop.InterleavedSamplingEvaluator(newEval, data, cfg.SampleSize, rng)
+    }
+    eval := newEval(data)
+    if sampler != nil {
+        eval = sampler
+    }
+    eval = pop.CountingEvaluator(eval, len(data.Output), counter)
+    // Define selection method and genetic operators
+    selector := newSelector(cfg, eval, data, rng)
+    acc := newAcceptance(cfg, eval, rng)
+    mut, err := parseMutation(cfg, gen, opset, data, eval, rng, acc)
+    if err != nil {
+        return Result{}, err
+    }
+    cross := newCrossover(cfg, opset, data, eval, rng, acc)
+
+    res := Result{ Run: run, Seed: seed }
+    report := func(gen int, evals pop.EvalCount, bCxChild, wCxChild float64, p pop.Population) {
+        row := stats.NewRow(run, seed, gen, evals, bCxChild, wCxChild, p, eval)
+        res.Rows = append(res.Rows, row)
+        if hook != nil {
+            hook(row, p, data)
+        }
+    }
+
+    // Create initial population and run its fitness
+    p := pop.CreatePopulation(cfg.PopSize, gen)
+    p, _ = p.Evaluate(eval, cfg.Threads)
+    evals := counter.Load()
+    last := evals
+    hof := pop.NewHallOfFame(cfg.HofSize, eval)
+    hof.Update(p, 0)
+    report(0, evals, 0, 0, p)
+
+    var betterCxChild, worseCxChild float64
+    for i := 1; i <= cfg.Generations; i++ {
+        if sampler != nil {
+            // a new sample is drawn, so the population is evaluated on it before selection
+            sampler.(pop.Scheduled).NextGeneration()
+            p.Invalidate()
+            p, _ = p.Evaluate(eval, cfg.Threads)
+            // the individuals were found in the previous generation
+            hof.Update(p, i-1)
+        }
+        // Selects new population
+        children := selector.Select(p, len(p))
+        // applies genetic operators
+        var cxindices []int
+        p, cxindices = pop.ApplyGeneticOps(children, cross, mut, cfg.CrossProb, cfg.MutProb, rng)
+        p, _ = p.Evaluate(eval, cfg.Threads)
+        betterCxChild, worseCxChild = pop.CompareChildren(children, p, cxindices, eval)
+        // every evaluation of the generation is counted, including the
+        // ones done by selection and acceptance of variation children
+        count := counter.Load()
+        evals, last = count.Sub(last), count
+        hof.Update(p, i)
+        if s, ok := acc.(pop.Scheduled); ok {
+            s.NextGeneration()
+        }
+        report(i, evals, betterCxChild, worseCxChild, p)
+    }
+
+    if sampler != nil {
+        // the final individuals are compared on every training row
+        full := newEval(data)
+        p.Invalidate()
+        p, _ = p.Evaluate(full, cfg.Threads)
+        hof.Reevaluate(full)
+        hof.Update(p, cfg.Generations)
+        eval = full
+    }
+    res.Best = unscale(cfg, p.Best(eval), scaler, train)
+    for _, m := range hof.Members() {
+        res.HallOfFame = append(res.HallOfFame, pop.Member{ Individual: unscale(cfg, m.Individual, scaler, train), Generation: m.Generation })
+    }
+    evaluateBest(cfg, d, &res)
+    return res, nil
+}
+
+// evaluateBest evaluates the best individual of the result on the validation and
+// test datasets. If there's a validation dataset, the best is the individual
+// among it and the hall of fame members that generalizes best to it
+func evaluateBest(cfg Config, d Data, res *Result) {
+    res.ValidationFitness = math.NaN()
+    if d.Validation != nil {
+        veval := NewEvaluator(cfg, d.Validation)
+        res.ValidationFitness = fitnessOn(cfg, res.Best, d.Validation)
+        for _, m := range res.HallOfFame {
+            if fit := fitnessOn(cfg, m.Individual, d.Validation); veval.CompareFitness(fit, res.ValidationFitness) {
+                res.Best, res.ValidationFitness = m.Individual, fit
+            }
+        }
+    }
+    res.TestFitness = math.NaN()
+    if d.Test != nil {
+        res.TestFitness = fitnessOn(cfg, res.Best, d.Test)
+        if cfg.Task == "classification" {
+            res.TestConfusion = pop.ConfusionMatrix(res.Best.Predict(d.Test), d.Test, cfg.Classes)
+        }
+    }
+}
+
+// fitnessOn returns the fitness of the individual on ds, or NaN if it's invalid
+func fitnessOn(cfg Config, ind *pop.Individual, ds *dataset.Dataset) float64 {
+    fit, ok := NewEvaluator(cfg, ds).(pop.SemanticEvaluator).SemanticFitness(ind.Predict(ds))
+    if !ok {
+        return math.NaN()
+    }
+    return fit
+}
+
+// unscale folds the scaling of the data into the individual, returning a new
+// individual that takes and predicts values in the original units, evaluated on ds.
+// Individuals are returned as they are if there's no scaler
+func unscale(cfg Config, ind *pop.Individual, scaler *dataset.Scaler, ds *dataset.Dataset) *pop.Individual {
+    if scaler == nil {
+        return ind
+    }
+    // x' = (x - offset) / scale
+    code := ind.Expr().SubstituteVariables(func(n int) operator.Expr {
+        return operator.Expr{
+            operator.Mul,
+            operator.Sub,
+            operator.Variable(ds.Variables[n], n),
+            operator.Constant(scaler.InputOffset[n]),
+            operator.Constant(1 / scaler.InputScale[n]),
+        }
+    })
+    // y = y' * scale + offset
+    if scaler.OutputScale != 1.0 || scaler.OutputOffset != 0.0 {
+        code = append(operator.Expr{ operator.Add, operator.Mul }, code...)
+        code = append(code, operator.Constant(scaler.OutputScale), operator.Constant(scaler.OutputOffset))
+    }
+    out := pop.Create(code)
+    out.Fitness, out.FitnessValid = NewEvaluator(cfg, ds).GetFitness(code)
+    return out
+}
+
+// newOpSet returns the operation set of the trees built from the given variables
+func newOpSet(cfg Config, variables []string) *operator.OpSet {
+    opset := operator.CreateOpSet(variables...)
+    if cfg.ERC > 0 {
+        opset.AddEphemeral(-cfg.ERC, cfg.ERC)
+    }
+    return opset
+}
+
+// OpcodeNames returns the names of every opcode that may appear in the trees
+// built from the given variables
+func OpcodeNames(cfg Config, variables []string) []string {
+    opset := newOpSet(cfg, variables)
+    names := []string{}
+    for _, op := range append(opset.Terminals, opset.Primitives...) {
+        names = append(names, op.String())
+    }
+    if cfg.Crossover == "gsgp" {
+        // geometric semantic trees also have logistic functions and constants
+        names = append(names, operator.Logistic.String())
+    }
+    if opset.Ephemeral || cfg.Crossover == "gsgp" {
+        names = append(names, pop.ConstantName)
+    }
+    return names
+}
+
+func newSelector(cfg Config, eval pop.Evaluator, ds *dataset.Dataset, rng *rand.Rand) pop.Selector {
+    transform := fitnessTransforms[cfg.RouletteTransform]
+    switch cfg.Selector {
+    case "rol":
+        return pop.RouletteSelector(cfg.Elitism, transform, eval, rng)
+    case "tour":
+        return pop.TournamentSelector(cfg.Elitism, cfg.TournamentSize, cfg.Threads, eval, rng)
+    case "lex":
+        caseEval := func(ds *dataset.Dataset) pop.Evaluator {
+            return NewEvaluator(cfg, ds)
+        }
+        return pop.LexicaseSelector(cfg.Elitism, cfg.Threads, eval, *ds, caseEval, rng)
+    case "rank":
+        return pop.LinearRankSelector(cfg.Elitism, cfg.RankPressure, eval, rng)
+    case "exprank":
+        return pop.ExponentialRankSelector(cfg.Elitism, cfg.RankBase, eval, rng)
+    case "sus":
+        return pop.SUSSelector(cfg.Elitism, transform, eval, rng)
+    case "boltz":
+        return pop.BoltzmannSelector(cfg.Elitism, cfg.Temperature, cfg.Cooling, eval, rng)
+    case "dtour":
+        return pop.DoubleTournamentSelector(cfg.Elitism, cfg.TournamentSize, cfg.ParsimonySize, eval, rng)
+    }
+    return pop.RandomSelector(cfg.Elitism, eval, rng)
+}
+
+func newAcceptance(cfg Config, eval pop.Evaluator, rng *rand.Rand) pop.Acceptance {
+    switch cfg.Acceptance {
+    case "always":
+        return pop.AlwaysAccept()
+    case "anneal":
+        return pop.AnnealingAcceptance(eval, cfg.AnnealTemp, cfg.AnnealCooling, rng)
+    case "tournament":
+        return pop.TournamentAcceptance(eval, cfg.AccProb, rng)
+    }
+    return pop.GreedyAcceptance(eval)
+}
+
+func newCrossover(cfg Config, opset *operator.OpSet, ds *dataset.Dataset, eval pop.Evaluator, rng *rand.Rand, acc pop.Acceptance) pop.Variation {
+    switch cfg.Crossover {
+    case "sizefair":
+        return pop.SizeFairCrossoverOp(rng, acc)
+    case "homologous":
+        return pop.HomologousCrossoverOp(rng, acc)
+    case "onepoint":
+        return pop.OnePointCrossoverOp(rng, acc)
+    case "uniform":
+        return pop.UniformCrossoverOp(rng, acc)
+    case "semantic":
+        return pop.SemanticCrossoverOp(ds, cfg.SemEps, eval, rng, acc)
+    case "gsgp":
+        return pop.GeometricCrossoverOp(pop.NewGrowGenerator(opset, 1, 3, rng), ds, eval, acc)
+    }
+    return pop.CrossoverOp(rng, acc)
+}
+
+// parseMutationSpec splits a comma separated list of mutation operator names,
+// each optionally followed by ':weight', into the names and their weights
+func parseMutationSpec(spec string) ([]string, []float64, error) {
+    names := []string{}
+    weights := []float64{}
+    for _, item := range strings.Split(spec, ",") {
+        name, wstr, hasWeight := strings.Cut(strings.TrimSpace(item), ":")
+        weight := 1.0
+        if hasWeight {
+            w, err := strconv.ParseFloat(wstr, 64)
+            if err != nil || w <= 0 {
+                return nil, nil, fmt.Errorf("invalid weight for mutation operator '%s'", name)
+            }
+            weight = w
+        }
+        switch name {
+        case "subtree", "point", "hoist", "shrink", "insert", "permute", "gauss", "gsgp":
+        default:
+            return nil, nil, fmt.Errorf("unknown mutation operator '%s'", name)
+        }
+        names = append(names, name)
+        weights = append(weights, weight)
+    }
+    return names, weights, nil
+}
+
+// parseMutation creates the mutation variation of the configuration.
+// More than one operator results in a weighted mixture of them
+func parseMutation(cfg Config, gen pop.Generator, opset *operator.OpSet, ds *dataset.Dataset, eval pop.Evaluator, rng *rand.Rand, acc pop.Acceptance) (pop.Variation, error) {
+    names, weights, err := parseMutationSpec(cfg.Mutation)
+    if err != nil {
+        return nil, err
+    }
+    ops := []pop.Variation{}
+    for _, name := range names {
+        var op pop.Variation
+        switch name {
+        case "subtree":
+            op = pop.MutationOp(gen, rng, acc)
+        case "point":
+            op = pop.PointMutationOp(opset, rng, acc)
+        case "hoist":
+            op = pop.HoistMutationOp(rng, acc)
+        case "shrink":
+            op = pop.ShrinkMutationOp(opset, rng, acc)
+        case "insert":
+            op = pop.InsertMutationOp(opset, rng, acc)
+        case "permute":
+            op = pop.PermutationMutationOp(rng, acc)
+        case "gauss":
+            op = pop.GaussianMutationOp(cfg.MutSigma, rng, acc)
+        case "gsgp":
+            op = pop.GeometricMutationOp(pop.NewGrowGenerator(opset, 1, 3, rng), ds, cfg.GSGPStep, eval, acc)
+        }
+        ops = append(ops, op)
+    }
+    if len(ops) == 1 {
+        return ops[0], nil
+    }
+    return pop.MixtureOp(ops, weights, rng), nil
+}
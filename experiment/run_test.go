@@ -0,0 +1,180 @@
+package experiment
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/franciscobonand/symb-regr-gp/datasets"
+	pop "github.com/franciscobonand/symb-regr-gp/population"
+	"github.com/franciscobonand/symb-regr-gp/stats"
+)
+
+// recorder is a reporter that keeps the rows it's given
+type recorder struct {
+    rows []stats.Row
+}
+
+func (r *recorder) Report(row stats.Row) error {
+    r.rows = append(r.rows, row)
+    return nil
+}
+
+func (r *recorder) Flush() error {
+    return nil
+}
+
+// testConfig returns a small valid configuration
+func testConfig() Config {
+    return Config{
+        PopSize: 30,
+        Generations: 8,
+        Threads: 4,
+        Selector: "tour",
+        TournamentSize: 2,
+        RouletteTransform: "window",
+        Crossover: "subtree",
+        Mutation: "subtree",
+        CrossProb: 0.9,
+        MutProb: 0.1,
+        Acceptance: "greedy",
+        Scale: "none",
+        Sampling: "none",
+        Task: "regression",
+    }
+}
+
+// testData returns a dataset of y = x0 * x1 + x0
+func testData(rows int) *dataset.Dataset {
+    ds := &dataset.Dataset{
+        Input: make([]float64, 2 * rows),
+        Output: make([]float64, rows),
+        Variables: []string{"x0", "x1"},
+    }
+    for i := 0; i < rows; i++ {
+        x0, x1 := float64(i % 7) - 3, float64(i % 5) / 2
+        ds.Input[i], ds.Input[rows + i] = x0, x1
+        ds.Output[i] = x0 * x1 + x0
+    }
+    return ds
+}
+
+// TestRunAllAsyncReporter runs multithreaded evolutions in parallel, reporting
+// their rows through an async reporter, and checks the rows of each run are
+// written in generation order. It's meant to be run with -race
+func TestRunAllAsyncReporter(t *testing.T) {
+    cfg := testConfig()
+    if err := cfg.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    const runs = 4
+    rec := &recorder{}
+    reporter := stats.NewAsyncReporter(rec, cfg.Generations + 1)
+    hooks := Hooks{
+        Generation: func(row stats.Row, p pop.Population, ds *dataset.Dataset) {
+            if err := reporter.Report(row); err != nil {
+                t.Error(err)
+            }
+        },
+    }
+    results, err := RunAll(cfg, Data{ Train: testData(200) }, runs, 3, 1, hooks)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := reporter.Flush(); err != nil {
+        t.Fatal(err)
+    }
+
+    if len(results) != runs {
+        t.Fatalf("got %d results, want %d", len(results), runs)
+    }
+    next := map[int64]int{}
+    for _, row := range rec.rows {
+        if row.Gen != next[row.Run] {
+            t.Fatalf("run %d: got generation %d, want %d", row.Run, row.Gen, next[row.Run])
+        }
+        next[row.Run]++
+    }
+    for run := int64(0); run < runs; run++ {
+        if next[run] != cfg.Generations + 1 {
+            t.Errorf("run %d: got %d rows, want %d", run, next[run], cfg.Generations + 1)
+        }
+        if len(results[run].Rows) != cfg.Generations + 1 {
+            t.Errorf("run %d: result has %d rows, want %d", run, len(results[run].Rows), cfg.Generations + 1)
+        }
+    }
+}
+
+// TestParallelRunsAreReproducible checks parallel runs have the seeds and
+// results of sequential ones, each run being seeded with seed+run
+func TestParallelRunsAreReproducible(t *testing.T) {
+    cfg := testConfig()
+    cfg.Threads = 1
+    if err := cfg.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    data := Data{ Train: testData(200) }
+    sequential, err := RunAll(cfg, data, 4, 1, 5, Hooks{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    parallel, err := RunAll(cfg, data, 4, 4, 5, Hooks{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for run, res := range parallel {
+        if res.Seed != 5 + int64(run) {
+            t.Errorf("run %d: got seed %d, want %d", run, res.Seed, 5 + run)
+        }
+        if !reflect.DeepEqual(res.Rows, sequential[run].Rows) || res.Best.String() != sequential[run].Best.String() {
+            t.Errorf("run %d: parallel result differs from the sequential one", run)
+        }
+        again, err := Run(cfg, data, int64(run), res.Seed, nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !reflect.DeepEqual(again.Rows, res.Rows) {
+            t.Errorf("run %d: running again with its seed gave a different result", run)
+        }
+    }
+}
+
+// TestOneVsRestStreamedRows checks the rows the hook receives from the runs of
+// the classes have distinct generations in order, while the result has a row per generation
+func TestOneVsRestStreamedRows(t *testing.T) {
+    cfg := testConfig()
+    cfg.Task = "classification"
+    cfg.Metric = "accuracy"
+    cfg.Multiclass = "ovr"
+    cfg.Classes = 3
+    if err := cfg.Validate(); err != nil {
+        t.Fatal(err)
+    }
+    ds := testData(90)
+    for i := range ds.Output {
+        ds.Output[i] = float64(i % cfg.Classes)
+    }
+    gens := []int{}
+    hook := func(row stats.Row, p pop.Population, ds *dataset.Dataset) {
+        gens = append(gens, row.Gen)
+    }
+    res, err := Run(cfg, Data{ Train: ds }, 0, 1, hook)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(gens) != cfg.Classes * (cfg.Generations + 1) {
+        t.Fatalf("got %d streamed rows, want %d", len(gens), cfg.Classes * (cfg.Generations + 1))
+    }
+    for i, gen := range gens {
+        if gen != i {
+            t.Fatalf("streamed row %d has generation %d", i, gen)
+        }
+    }
+    for i, row := range res.Rows {
+        if row.Gen != i {
+            t.Errorf("result row %d has generation %d", i, row.Gen)
+        }
+    }
+    if len(res.Rows) != cfg.Generations + 1 {
+        t.Errorf("got %d result rows, want %d", len(res.Rows), cfg.Generations + 1)
+    }
+}
@@ -0,0 +1,164 @@
+package experiment
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Grid holds the values of each swept parameter
+type Grid struct {
+    PopSize        []int
+    Generations    []int
+    CrossProb      []float64
+    MutProb        []float64
+    Selector       []string
+    TournamentSize []int
+    Elitism        []int
+}
+
+// Configs returns a copy of base for every combination of the values of the grid
+func (g Grid) Configs(base Config) []Config {
+    cfgs := []Config{}
+    for _, popSize := range g.PopSize {
+        for _, gens := range g.Generations {
+            for _, cxProb := range g.CrossProb {
+                for _, mutProb := range g.MutProb {
+                    for _, sel := range g.Selector {
+                        for _, tsize := range g.TournamentSize {
+                            for _, elitism := range g.Elitism {
+                                cfg := base
+                                cfg.PopSize = popSize
+                                cfg.Generations = gens
+                                cfg.CrossProb = cxProb
+                                cfg.MutProb = mutProb
+                                cfg.Selector = sel
+                                cfg.TournamentSize = tsize
+                                cfg.Elitism = elitism
+                                cfgs = append(cfgs, cfg)
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    }
+    return cfgs
+}
+
+// ParseInts parses a comma separated list of integers and 'start:end[:step]'
+// ranges, which include both ends
+func ParseInts(spec string) ([]int, error) {
+    floats, err := ParseFloats(spec)
+    if err != nil {
+        return nil, err
+    }
+    ints := make([]int, len(floats))
+    for i, f := range floats {
+        if f != math.Trunc(f) {
+            return nil, fmt.Errorf("'%s' has non integer values", spec)
+        }
+        ints[i] = int(f)
+    }
+    return ints, nil
+}
+
+// ParseFloats parses a comma separated list of numbers and 'start:end[:step]'
+// ranges, which include both ends
+func ParseFloats(spec string) ([]float64, error) {
+    vals := []float64{}
+    for _, item := range strings.Split(spec, ",") {
+        bounds := strings.Split(strings.TrimSpace(item), ":")
+        nums := make([]float64, len(bounds))
+        for i, b := range bounds {
+            n, err := strconv.ParseFloat(b, 64)
+            if err != nil {
+                return nil, fmt.Errorf("invalid number '%s' in '%s'", b, spec)
+            }
+            nums[i] = n
+        }
+        switch len(nums) {
+        case 1:
+            vals = append(vals, nums[0])
+        case 2, 3:
+            start, end, step := nums[0], nums[1], 1.0
+            if len(nums) == 3 {
+                step = nums[2]
+            }
+            if step <= 0 || end < start {
+                return nil, fmt.Errorf("invalid range '%s', must be 'start:end[:step]' with start <= end and positive step", item)
+            }
+            // a small tolerance keeps the end of ranges with fractional steps,
+            // and rounding removes the floating point error of the values
+            n := int(math.Floor((end - start) / step + 1e-9))
+            for i := 0; i <= n; i++ {
+                vals = append(vals, math.Round((start + float64(i) * step) * 1e9) / 1e9)
+            }
+        default:
+            return nil, fmt.Errorf("invalid range '%s', must be 'start:end[:step]'", item)
+        }
+    }
+    return vals, nil
+}
+
+// ParseStrings parses a comma separated list of names
+func ParseStrings(spec string) []string {
+    vals := strings.Split(spec, ",")
+    for i := range vals {
+        vals[i] = strings.TrimSpace(vals[i])
+    }
+    return vals
+}
+
+// Sweep executes every configuration runs times, up to parallel configurations
+// at the same time, and returns their results in the order of the configurations.
+// done, if not nil, is called with the index and results of each configuration
+// once its runs end, never concurrently.
+// As in RunAll, run i of every configuration is seeded with seed+i, so the results
+// are the same whether configurations are executed in parallel or not
+func Sweep(cfgs []Config, data Data, runs, parallel int, seed int64, done func(i int, results []Result)) ([][]Result, error) {
+    results := make([][]Result, len(cfgs))
+    errs := make([]error, len(cfgs))
+
+    seed = SetSeed(seed)
+    var mu sync.Mutex
+    execute := func(i int) {
+        results[i], errs[i] = RunAll(cfgs[i], data, runs, 1, seed, Hooks{})
+        if errs[i] == nil && done != nil {
+            mu.Lock()
+            defer mu.Unlock()
+            done(i, results[i])
+        }
+    }
+
+    if parallel <= 1 {
+        for i := range cfgs {
+            execute(i)
+            if errs[i] != nil {
+                return nil, errs[i]
+            }
+        }
+        return results, nil
+    }
+
+    var wg sync.WaitGroup
+    slots := make(chan struct{}, parallel)
+    for i := range cfgs {
+        wg.Add(1)
+        slots <- struct{}{}
+        go func(i int) {
+            defer wg.Done()
+            execute(i)
+            <-slots
+        }(i)
+    }
+    wg.Wait()
+    for _, err := range errs {
+        if err != nil {
+            return nil, err
+        }
+    }
+    return results, nil
+}
@@ -0,0 +1,35 @@
+package experiment
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSweepParallelIsReproducible checks configurations executed in parallel
+// have the seeds and results of sequential ones
+func TestSweepParallelIsReproducible(t *testing.T) {
+    cfgs := []Config{testConfig(), testConfig(), testConfig()}
+    for i := range cfgs {
+        cfgs[i].Threads = 1
+        cfgs[i].PopSize = 20 + 10 * i
+    }
+    data := Data{ Train: testData(100) }
+    sequential, err := Sweep(cfgs, data, 2, 1, 3, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    parallel, err := Sweep(cfgs, data, 2, 3, 3, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i, results := range parallel {
+        for run, res := range results {
+            if res.Seed != 3 + int64(run) {
+                t.Errorf("configuration %d, run %d: got seed %d, want %d", i, run, res.Seed, 3 + run)
+            }
+            if !reflect.DeepEqual(res.Rows, sequential[i][run].Rows) {
+                t.Errorf("configuration %d, run %d: parallel result differs from the sequential one", i, run)
+            }
+        }
+    }
+}
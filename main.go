@@ -1,128 +1,171 @@
 package main
 
 import (
-    crand "crypto/rand"
+    "encoding/json"
     "flag"
     "fmt"
-    "math/big"
+    "io"
     "math/rand"
-    "sync"
+    "os"
+    "runtime"
+    "text/tabwriter"
 
     "github.com/franciscobonand/symb-regr-gp/datasets"
-    "github.com/franciscobonand/symb-regr-gp/operator"
+    "github.com/franciscobonand/symb-regr-gp/experiment"
     pop "github.com/franciscobonand/symb-regr-gp/population"
     "github.com/franciscobonand/symb-regr-gp/stats"
 )
 
 var (
-    popSize, tournamentSize, threads, generations, nElitism int
-    file, sel, statsfile string
-    crossProb, mutProb float64
+    popSize, tournamentSize, threads, generations, nElitism, divSample, hofSize, runs, parallel int
+    file, sel, statsfile, rolTransform, mutation, crossover, acceptance, diversityfile, report, hoffile string
+    testfile, summaryfile, scale, missing, sampling, format, weight string
+    task, metric, multiclass string
+    crossProb, mutProb, ercRange, mutSigma, semEps float64
+    annealTemp, annealCooling, accProb, gsgpStep float64
+    rankPressure, rankBase, temperature, cooling, parsimonySize float64
+    scaleTarget bool
+    valFrac, testFrac float64
+    kfold, sampleSize int
     seed int64
 )
 
 func main() {
-    // ./symb-regr-gp -popsize 20 -selector tour -toursize 2 -gens 20 -threads 1 -file "abcd.csv" -cxprob 0.9 -mutprob 0.05 -elitism 0 -seed 4132 -getstats
+    if len(os.Args) > 1 {
+        switch os.Args[1] {
+        case "sweep":
+            sweepMain(os.Args[2:])
+            return
+        case "compare":
+            compareMain(os.Args[2:])
+            return
+        case "convert":
+            convertMain(os.Args[2:])
+            return
+        }
+    }
+    // ./symb-regr-gp -popsize 20 -selector tour -toursize 2 -gens 20 -threads 1 -file "abcd.csv" -cxprob 0.9 -mutprob 0.05 -elitism 0 -seed 4132 -runs 30 -statsfile "stats.csv"
     initializeFlags()
 
-    if !allPositiveInts(popSize, threads, generations) {
-        panic("Invalid value for popsize, gens or threads, must be a positive integer")
+    cfg := newConfig()
+    if err := cfg.Validate(); err != nil {
+        panic(err.Error())
     }
-    if nElitism < 0 {
-        panic("Elitism size must be at least 0")
+    if !allPositiveInts(runs, parallel) {
+        panic("Invalid value for runs or parallel, must be a positive integer")
     }
-    if sel == "tour" && tournamentSize < 2 {
-        panic("Tournament size must be at least 2")
+    if divSample < 2 {
+        panic("Diversity sample size must be at least 2")
     }
-    if crossProb < 0.0 || mutProb < 0.0 || crossProb > 1.0 || mutProb > 1.0 {
-        panic("Genetic operators probability must be between 0.0 and 1.0")
+    if kfold == 1 || kfold < 0 {
+        panic("Number of cross-validation folds must be at least 2")
     }
 
-    // fmt.Println(popSize, tournamentSize, threads, file, crossProb, mutProb, seed)
-    ds, err := dataset.Read(file)
-    if err != nil {
-        panic(err.Error())
-    }
+    data := readDatasets()
+    ds := data.Train
+    setClasses(&cfg, data)
 
-    var runqnt int64 = 1
-    var run int64
     getstats := statsfile != ""
-    if getstats {
-        runqnt = 30
-    }
-
-    rundata := [][]float64{}
-    for run = 0; run < runqnt; run++ {
-        setSeed(seed + run)
-        opset := operator.CreateOpSet(ds.Variables...)
-        gen := pop.NewRampedGenerator(opset, 1, 6)
-        rmse := pop.RMSE{ DS: ds }
-        // Create initial population
-        p := pop.CreatePopulation(popSize, gen)
-        // Define selection method and genetic operators
-        var selector pop.Selector
-        if sel == "rol" {
-            selector = pop.RouletteSelector(nElitism, rmse) 
-        } else if sel == "tour" {
-            selector = pop.TournamentSelector(nElitism, tournamentSize, threads, rmse)
-        } else if sel == "lex" {
-            selector = pop.LexicaseSelector(nElitism, threads, rmse, ds.Copy())
-        } else {
-            selector = pop.RandomSelector(nElitism)
+
+    var divfile *os.File
+    var opnames []string
+    if diversityfile != "" {
+        f, err := stats.CreateFile(diversityfile)
+        if err != nil {
+            panic(err.Error())
         }
-        mut := pop.MutationOp(gen, rmse)
-        cross := pop.CrossoverOp(rmse)
-        // Run Fitness for initial population
-        p, e := p.Evaluate(rmse, threads)
-
-        var betterCxChild, worseCxChild float64
-        var wg sync.WaitGroup
-        if !getstats {
-            wg.Add(generations + 1)
-            fmt.Println("gen,evals,repeated,bestfit,worstfit,meanfit,maxsize,minsize,meansize,betterCxChild,worseCxChild")
-            go stats.PrintRunStats(&wg, 0, float64(e), betterCxChild, worseCxChild, p, rmse)
+        defer f.Close()
+        divfile = f
+        opnames = experiment.OpcodeNames(cfg, ds.Variables)
+        fmt.Fprint(divfile, stats.DiversityHeader(opnames))
+    }
+
+    var hofout io.Writer = os.Stdout
+    if hofSize > 0 && hoffile != "" {
+        f, err := stats.CreateFile(hoffile)
+        if err != nil {
+            panic(err.Error())
         }
+        defer f.Close()
+        hofout = f
+    }
 
-        rundata = append(rundata, stats.GetRunStats(0.0, float64(e), betterCxChild, worseCxChild, p, rmse))                
-        fgen := float64(generations)
-        for i := 0.0; i < fgen; i++ {
-            // Selects new population
-            children := selector.Select(p, len(p))
-            // appleis genetic operators
-            p, betterCxChild, worseCxChild = pop.ApplyGeneticOps(children, cross, mut, crossProb, mutProb)
-            p, e = p.Evaluate(rmse, threads)
-            // print new population stats
-            if getstats {
-                rundata = append(rundata, stats.GetRunStats(i+1.0, float64(e), betterCxChild, worseCxChild, p, rmse))                
-            } else {
-                go stats.PrintRunStats(&wg, i+1.0, float64(e), betterCxChild, worseCxChild, p, rmse) 
+    writer, err := stats.NewReporter(report, os.Stdout)
+    if err != nil {
+        panic(err.Error())
+    }
+    // stats are written by a single goroutine, in generation order
+    reporter := stats.NewAsyncReporter(writer, generations + 1)
+
+    hofHeader := false
+    hooks := experiment.Hooks{
+        Generation: func(row stats.Row, p pop.Population, data *dataset.Dataset) {
+            if !getstats {
+                reportRow(reporter, row)
             }
-        }
+            if divfile != nil {
+                fmt.Fprint(divfile, stats.DiversityLine(row.Run, float64(row.Gen), p.GetDiversity(data, divSample), opnames))
+            }
+        },
+        Done: func(r experiment.Result) {
+            if err := reporter.Flush(); err != nil {
+                panic(err.Error())
+            }
+            // JSON lines already have the best individual of each generation
+            if report != "json" {
+                fmt.Println(r.Best)
+                if r.TestConfusion != nil {
+                    printConfusion(r.TestConfusion)
+                }
+            }
+            if hofSize > 0 {
+                if !hofHeader {
+                    hofHeader = true
+                    fmt.Fprint(hofout, stats.HallOfFameHeader)
+                }
+                if err := stats.WriteHallOfFame(hofout, r.Run, r.HallOfFame); err != nil {
+                    panic(err.Error())
+                }
+            }
+        },
+    }
+    var results []experiment.Result
+    if kfold > 0 {
+        results, err = experiment.CrossValidate(cfg, kFolds(data), parallel, seed, hooks)
+    } else {
+        results, err = experiment.RunAll(cfg, data, runs, parallel, seed, hooks)
+    }
+    if err != nil {
+        panic(err.Error())
+    }
 
-        if !getstats {
-            wg.Wait()
+    summaries := make([]stats.Summary, len(results))
+    for i, r := range results {
+        summaries[i] = r.Summary()
+    }
+    if len(summaries) > 1 {
+        if report == "json" {
+            printSummariesJSON(summaries)
+        } else {
+            printSummaries(summaries)
+        }
+    }
+    if summaryfile != "" {
+        if err := writeSummaries(summaryfile, summaries); err != nil {
+            fmt.Println("(ERROR) failed to write summary file:", err.Error())
         }
-        best := p.Best(rmse)
-        fmt.Println(best)
     }
     if getstats {
-        output := [][]float64{}
-        fmt.Println("Writing stats to file...")
-        for k := 0; k <= generations; k++ {
-            currgen := []float64{}
-            for col := 1; col < len(rundata[0]); col++ {
-                acc := 0.0
-                for lin := k; lin < len(rundata); lin += (generations + 1) {
-                    acc += rundata[lin][col]
-                }
-                currgen = append(currgen, acc/30.0)
-            }
-            output = append(output, currgen)
+        rundata := make([][]stats.Row, len(results))
+        for i, r := range results {
+            rundata[i] = r.Rows
         }
-        if err := dataset.Write(statsfile, output); err != nil {
+        fmt.Println("Writing stats to file...")
+        output := stats.Aggregate(rundata, experiment.NewEvaluator(cfg, ds).CompareFitness)
+        if err := writeAggregate(statsfile, output); err != nil {
             fmt.Println("(ERROR) failed to write stats file:", err.Error())
         } else {
-            fmt.Printf("Stats file available in 'analysis/%s'\n", statsfile)
+            fmt.Printf("Stats file available in '%s'\n", statsfile)
         }
     }
 }
@@ -131,26 +174,278 @@ func initializeFlags() {
     flag.IntVar(&popSize, "popsize", 20, "population size")
     flag.IntVar(&nElitism, "elitism", 0, "number of best members of elitism")
     flag.IntVar(&tournamentSize, "toursize", 2, "tournament size")
-    flag.StringVar(&sel, "selector", "tour", "defines the selection method ('rol', 'tour', 'lex', or 'rand')")
-    flag.StringVar(&statsfile, "statsfile", "", "generate stats and saves into given file")
+    flag.StringVar(&sel, "selector", "tour", "defines the selection method ('rol', 'tour', 'lex', 'rank', 'exprank', 'sus', 'boltz', 'dtour' or 'rand')")
     flag.IntVar(&generations, "gens", 10, "number of generations to run")
-    flag.IntVar(&threads, "threads", 1, "quantity of threads to be used when evaluating")
-    flag.StringVar(&file, "file", "datasets/synth1/synth1-train.csv", "csv file containing data to be processed")
     flag.Float64Var(&crossProb, "cxprob", 0.9, "crossover probability")
     flag.Float64Var(&mutProb, "mutprob", 0.05, "mutation probability")
-    flag.Int64Var(&seed, "seed", 1, "seed for generating the initial population")
+    flag.StringVar(&report, "report", "csv", "format of the stats of each generation ('csv', 'json' or 'table')")
+    flag.StringVar(&statsfile, "statsfile", "", "if set, saves the stats of every generation aggregated across runs into the given file")
+    flag.IntVar(&parallel, "parallel", 1, "maximum number of runs executed at the same time")
+    flag.StringVar(&summaryfile, "summaryfile", "", "if set, writes the final train/test fitness and size of every run into the given csv file")
+    flag.IntVar(&hofSize, "hof", 0, "if positive, keeps the given number of best individuals ever found and writes them at the end of the run")
+    flag.StringVar(&hoffile, "hoffile", "", "csv file to write the hall of fame into (default stdout)")
+    flag.StringVar(&diversityfile, "diversityfile", "", "if set, writes the population diversity stats of every generation into the given csv file")
+    flag.IntVar(&kfold, "kfold", 0, "if at least 2, runs k-fold cross-validation on the training data, once per fold, reporting the held-out error")
+    flag.IntVar(&divSample, "divsample", 20, "number of individuals sampled to calculate the mean tree edit distance")
+    commonFlags(flag.CommandLine)
     flag.Parse()
 }
 
-// setSeed sets the given number as seed, or a random value if seed is <= 0
-func setSeed(seed int64) int64 {
-    if seed <= 0 {
-        max := big.NewInt(2<<31 - 1)
-        rseed, _ := crand.Int(crand.Reader, max)
-        seed = rseed.Int64()
+// commonFlags defines the flags shared by single experiments and parameter sweeps
+func commonFlags(fs *flag.FlagSet) {
+    fs.StringVar(&rolTransform, "roltransform", "window", "fitness transform of roulette and SUS selection ('window', 'inverse' or 'rank')")
+    fs.Float64Var(&rankPressure, "rankpressure", 1.5, "selective pressure of linear rank selection (between 1.0 and 2.0)")
+    fs.Float64Var(&rankBase, "rankbase", 0.9, "base of exponential rank selection (between 0.0 and 1.0)")
+    fs.Float64Var(&temperature, "temp", 10.0, "initial temperature of Boltzmann selection")
+    fs.Float64Var(&cooling, "cooling", 0.9, "temperature decay rate per generation of Boltzmann selection")
+    fs.Float64Var(&parsimonySize, "parsimony", 1.4, "parsimony tournament size of double tournament (between 1.0 and 2.0)")
+    fs.IntVar(&runs, "runs", 1, "number of independent runs")
+    fs.IntVar(&threads, "threads", 1, "quantity of threads to be used when evaluating")
+    fs.StringVar(&file, "file", "datasets/synth1/synth1-train.csv", "csv file containing data to be processed")
+    fs.StringVar(&testfile, "testfile", "", "csv file containing test data, used to evaluate the best individual of each run")
+    fs.StringVar(&crossover, "crossover", "subtree", "crossover operator ('subtree', 'sizefair', 'homologous', 'onepoint', 'uniform', 'semantic' or 'gsgp')")
+    fs.Float64Var(&semEps, "semeps", 1e-3, "minimum output difference for subtrees to be considered semantically distinct in semantic crossover")
+    fs.StringVar(&acceptance, "acceptance", "greedy", "acceptance policy of variation children ('greedy', 'always', 'anneal' or 'tournament')")
+    fs.Float64Var(&annealTemp, "annealtemp", 1.0, "initial temperature of the annealing acceptance policy")
+    fs.Float64Var(&annealCooling, "annealcooling", 0.9, "temperature decay rate per generation of the annealing acceptance policy")
+    fs.Float64Var(&accProb, "accprob", 0.9, "probability of the better of parent and child surviving in the tournament acceptance policy")
+    fs.StringVar(&mutation, "mutation", "subtree", "comma separated mutation operators, each optionally weighted as 'name:weight' ('subtree', 'point', 'hoist', 'shrink', 'insert', 'permute', 'gauss' or 'gsgp')")
+    fs.Float64Var(&gsgpStep, "gsgpstep", 0.1, "mutation step of the geometric semantic mutation")
+    fs.Float64Var(&mutSigma, "mutsigma", 0.1, "standard deviation of the gaussian constant mutation")
+    fs.Float64Var(&ercRange, "erc", 0.0, "if positive, ephemeral random constants in [-erc, erc] are used as terminals")
+    fs.Float64Var(&valFrac, "valfrac", 0.0, "fraction of the training rows held out as validation set, used to select the final individual from the hall of fame")
+    fs.Float64Var(&testFrac, "testfrac", 0.0, "fraction of the training rows held out as test set")
+    fs.StringVar(&format, "format", "auto", "format of the dataset files ('auto', 'csv', 'tsv', 'whitespace', 'jsonl' or 'binary')")
+    fs.StringVar(&weight, "weight", "", "if set, name of the input column with the weight of each row in the fitness evaluation")
+    fs.StringVar(&missing, "missing", "fail", "policy for rows with missing or non-finite values ('fail', 'skip', 'mean' or 'median')")
+    fs.StringVar(&scale, "scale", "none", "scaling of the data fitted on the training data ('none', 'minmax' or 'zscore'), folded back into the final individuals")
+    fs.BoolVar(&scaleTarget, "scaletarget", true, "whether the target is also scaled when the data is scaled")
+    fs.StringVar(&sampling, "sampling", "none", "evaluation on a sample of the training rows drawn every generation ('none', 'random' or 'interleaved'), the final individuals being compared on every row")
+    fs.IntVar(&sampleSize, "samplesize", 100, "number of training rows of each sample")
+    fs.StringVar(&task, "task", "regression", "'regression' or 'classification', whose outputs must be class labels 0, 1, 2...")
+    fs.StringVar(&metric, "metric", "accuracy", "fitness of classification ('accuracy', 'balanced', 'logloss' or 'f1')")
+    fs.StringVar(&multiclass, "multiclass", "ordinal", "classification of more than two classes by a tree with ordered classes ('ordinal') or a tree per class ('ovr')")
+    fs.Int64Var(&seed, "seed", 1, "seed for generating the initial population")
+}
+
+// newConfig returns the run configuration defined by the flags
+func newConfig() experiment.Config {
+    return experiment.Config{
+        PopSize: popSize,
+        Generations: generations,
+        Threads: threads,
+        Elitism: nElitism,
+        Selector: sel,
+        TournamentSize: tournamentSize,
+        RouletteTransform: rolTransform,
+        RankPressure: rankPressure,
+        RankBase: rankBase,
+        Temperature: temperature,
+        Cooling: cooling,
+        ParsimonySize: parsimonySize,
+        Crossover: crossover,
+        Mutation: mutation,
+        CrossProb: crossProb,
+        MutProb: mutProb,
+        SemEps: semEps,
+        MutSigma: mutSigma,
+        GSGPStep: gsgpStep,
+        ERC: ercRange,
+        Acceptance: acceptance,
+        AnnealTemp: annealTemp,
+        AnnealCooling: annealCooling,
+        AccProb: accProb,
+        HofSize: hofSize,
+        Scale: scale,
+        ScaleTarget: scaleTarget,
+        Sampling: sampling,
+        SampleSize: sampleSize,
+        Task: task,
+        Metric: metric,
+        Multiclass: multiclass,
+    }
+}
+
+// reportRow writes the stats row, stopping the program if it fails
+func reportRow(reporter stats.Reporter, row stats.Row) {
+    if err := reporter.Report(row); err != nil {
+        panic(err.Error())
+    }
+}
+
+// readDatasets reads the training dataset and, if given, the test dataset,
+// splitting validation and test rows from the training dataset if asked to.
+// Missing test values are imputed with the values computed on the training dataset
+func readDatasets() experiment.Data {
+    if valFrac < 0.0 || testFrac < 0.0 || valFrac + testFrac >= 1.0 {
+        panic("Validation and test fractions must be at least 0.0 and add up to less than 1.0")
+    }
+    if testFrac > 0.0 && testfile != "" {
+        panic("Test rows can't be split from the training file when a test file is given")
+    }
+    opts := dataset.ReadOptions{ Format: dataset.Format(format), Missing: dataset.MissingPolicy(missing), Weight: weight }
+    ds, report, err := dataset.ReadWith(file, opts)
+    if err != nil {
+        panic(err.Error())
+    }
+    printReadReport(file, report)
+    data := experiment.Data{ Train: ds }
+    if valFrac > 0.0 || testFrac > 0.0 {
+        data.Train, data.Validation, data.Test = ds.Split(rand.New(rand.NewSource(seed)), valFrac, testFrac)
+    }
+    if testfile != "" {
+        opts.Fill = report.Fill
+        data.Test, report, err = dataset.ReadWith(testfile, opts)
+        if err != nil {
+            panic(err.Error())
+        }
+        printReadReport(testfile, report)
+    }
+    return data
+}
+
+// kFolds splits the training dataset into the folds of k-fold cross-validation,
+// each one using its held-out rows as test dataset
+func kFolds(data experiment.Data) []experiment.Data {
+    if data.Test != nil {
+        panic("Cross-validation holds out its own test rows, it can't be used with a test file or test fraction")
+    }
+    if kfold > data.Train.Rows() {
+        panic("Number of cross-validation folds can't be greater than the number of training rows")
+    }
+    // a different source from the one of the split, so the folds aren't correlated to it
+    train, heldout := data.Train.KFold(rand.New(rand.NewSource(seed + 1)), kfold)
+    folds := make([]experiment.Data, kfold)
+    for i := range folds {
+        folds[i] = experiment.Data{ Train: train[i], Validation: data.Validation, Test: heldout[i] }
+    }
+    return folds
+}
+
+// setClasses sets the number of classes of a classification configuration,
+// stopping the program if the outputs aren't valid class labels
+func setClasses(cfg *experiment.Config, data experiment.Data) {
+    if cfg.Task != "classification" {
+        return
+    }
+    classes, err := experiment.CountClasses(data)
+    if err != nil {
+        panic(err.Error())
+    }
+    cfg.Classes = classes
+}
+
+// printConfusion prints the confusion matrix of the best individual on the test dataset
+func printConfusion(m [][]float64) {
+    fmt.Println("Test confusion matrix (rows are the true classes, columns the predicted ones):")
+    w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+    fmt.Fprint(w, "\t")
+    for c := range m {
+        fmt.Fprintf(w, "%d\t", c)
+    }
+    fmt.Fprintln(w)
+    for c, row := range m {
+        fmt.Fprintf(w, "%d\t", c)
+        for _, n := range row {
+            fmt.Fprintf(w, "%g\t", n)
+        }
+        fmt.Fprintln(w)
+    }
+    w.Flush()
+}
+
+// printReadReport warns about the rows of a dataset file that were skipped or imputed,
+// and reports the memory used by big datasets
+func printReadReport(fname string, report dataset.ReadReport) {
+    if report.Skipped > 0 || report.Imputed > 0 {
+        fmt.Fprintf(os.Stderr, "%s: %d rows read, %d rows skipped, %d values imputed\n", fname, report.Rows, report.Skipped, report.Imputed)
+    }
+    if report.Bytes >= 1<<20 {
+        var mem runtime.MemStats
+        runtime.ReadMemStats(&mem)
+        fmt.Fprintf(os.Stderr, "%s: %d rows using %.1f MB (heap in use: %.1f MB)\n", fname, report.Rows, float64(report.Bytes)/(1<<20), float64(mem.HeapInuse)/(1<<20))
+    }
+}
+
+// summaryDistributions returns the names and values of the final results of
+// the runs whose distributions are printed, in the order they're printed
+func summaryDistributions(summaries []stats.Summary) ([]string, [][]float64) {
+    train := make([]float64, len(summaries))
+    val := make([]float64, len(summaries))
+    test := make([]float64, len(summaries))
+    size := make([]float64, len(summaries))
+    for i, s := range summaries {
+        train[i], val[i], test[i], size[i] = s.TrainFit, s.ValFit, s.TestFit, float64(s.Size)
+    }
+    names, values := []string{"trainfit"}, [][]float64{train}
+    if valFrac > 0.0 {
+        names, values = append(names, "valfit"), append(values, val)
+    }
+    if kfold > 0 {
+        names, values = append(names, "heldout"), append(values, test)
+    } else if testfile != "" || testFrac > 0.0 {
+        names, values = append(names, "testfit"), append(values, test)
+    }
+    return append(names, "size"), append(values, size)
+}
+
+// printSummaries prints the distribution of the final results of the runs
+func printSummaries(summaries []stats.Summary) {
+    if kfold > 0 {
+        fmt.Printf("%d-fold cross-validation:\n", len(summaries))
+    } else {
+        fmt.Printf("%d runs:\n", len(summaries))
+    }
+    names, values := summaryDistributions(summaries)
+    for i, name := range names {
+        printDistribution(name, values[i])
+    }
+}
+
+func printDistribution(name string, values []float64) {
+    d := stats.Describe(values)
+    fmt.Printf("%-8s mean %.3f  std %.3f  median %.3f  q1 %.3f  q3 %.3f\n", name, d.Mean, d.Std, d.Median, d.Q1, d.Q3)
+}
+
+// printSummariesJSON prints the distribution of the final results of the runs as
+// a JSON line, with the number of runs (or folds) and an object per result
+func printSummariesJSON(summaries []stats.Summary) {
+    line := map[string]interface{}{}
+    if kfold > 0 {
+        line["folds"] = len(summaries)
+    } else {
+        line["runs"] = len(summaries)
+    }
+    names, values := summaryDistributions(summaries)
+    for i, name := range names {
+        line[name] = stats.Describe(values[i])
+    }
+    if err := json.NewEncoder(os.Stdout).Encode(line); err != nil {
+        panic(err.Error())
+    }
+}
+
+// writeAggregate writes the stats aggregated across runs into the given csv file
+func writeAggregate(fname string, data [][]float64) error {
+    f, err := stats.CreateFile(fname)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    return stats.WriteAggregate(f, data)
+}
+
+// writeSummaries writes the final results of every run into the given csv file
+func writeSummaries(fname string, summaries []stats.Summary) error {
+    f, err := stats.CreateFile(fname)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    if _, err := fmt.Fprint(f, stats.SummaryHeader); err != nil {
+        return err
     }
-    rand.Seed(seed)
-    return seed
+    return stats.WriteSummaries(f, summaries)
 }
 
 func allPositiveInts(nums... int) bool {
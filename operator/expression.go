@@ -2,6 +2,7 @@ package operator
 
 import (
 	"math/rand"
+	"sort"
 )
 
 // Opcode is an individual's genome smallest part
@@ -68,6 +69,67 @@ func (e Expr) Eval(input ...float64) float64 {
 	return doEval()
 }
 
+// blockSize is the number of rows evaluated at a time by EvalColumns
+const blockSize = 256
+
+// EvalColumns evaluates the expression on n rows given column-major, columns[j][i]
+// being the value of variable j on row i. Each node is evaluated on a block of
+// rows at a time, which keeps the values in cache and avoids the recursion of Eval
+func (e Expr) EvalColumns(columns [][]float64, n int) []float64 {
+	out := make([]float64, n)
+	stack := [][]float64{}
+	free := [][]float64{}
+	args := []float64{}
+	for start := 0; start < n; start += blockSize {
+		end := start + blockSize
+		if end > n {
+			end = n
+		}
+		// prefix expressions are evaluated backwards, so the arguments
+		// of an operation are on top of the stack in reverse order
+		for pos := len(e) - 1; pos >= 0; pos-- {
+			var buf []float64
+			if len(free) > 0 {
+				buf, free = free[len(free)-1], free[:len(free)-1]
+			} else {
+				buf = make([]float64, blockSize)
+			}
+			buf = buf[:end-start]
+			op := e[pos]
+			arity := op.Arity()
+			if arity == 0 {
+				if j, ok := VariableIndex(op); ok {
+					copy(buf, columns[j][start:end])
+				} else {
+					v := op.Eval()
+					for i := range buf {
+						buf[i] = v
+					}
+				}
+			} else {
+				top := len(stack)
+				if cap(args) < arity {
+					args = make([]float64, arity)
+				}
+				args = args[:arity]
+				for i := range buf {
+					for k := range args {
+						args[k] = stack[top-1-k][i]
+					}
+					buf[i] = op.Eval(args...)
+				}
+				free = append(free, stack[top-arity:]...)
+				stack = stack[:top-arity]
+			}
+			stack = append(stack, buf)
+		}
+		copy(out[start:end], stack[0])
+		free = append(free, stack[0])
+		stack = stack[:0]
+	}
+	return out
+}
+
 // Format returns a string representation of an expression.
 // It calls the Format method on each Opcode to return a result in infix notation.
 func (e Expr) Format() string {
@@ -83,6 +145,25 @@ func (e Expr) Format() string {
 	return list[0]
 }
 
+// Canonical returns a string representation of an expression in which the arguments
+// of commutative operators are sorted, so equivalent orderings have the same representation
+func (e Expr) Canonical() string {
+	list := []string{}
+	node := func(op Opcode) {
+		end := len(list) - op.Arity()
+		args := append([]string{}, list[end:]...)
+		if IsCommutative(op) {
+			sort.Strings(args)
+		}
+		list = append(list[:end], op.Format(args...))
+	}
+	term := func(op Opcode) {
+		list = append(list, op.Format())
+	}
+	e.Traverse(0, node, term)
+	return list[0]
+}
+
 // Depth returns the maximum height of the code tree from the root.
 func (e Expr) Depth() int {
 	stack := make([]int, 1, len(e))
@@ -111,10 +192,43 @@ func (e Expr) ReplaceSubtree(pos int, subtree Expr) Expr {
 	return append(e[:pos], tail...)
 }
 
-// RandomSubtree returns postion and a copy of nodes in randomly selected subtree of code
-func (e Expr) RandomSubtree() (pos int, subtree Expr) {
-	pos = rand.Intn(len(e))
+// RandomSubtree returns postion and a copy of nodes in a subtree of code randomly selected with rng
+func (e Expr) RandomSubtree(rng *rand.Rand) (pos int, subtree Expr) {
+	pos = rng.Intn(len(e))
 	end := e.Traverse(pos, nil, nil)
 	subtree = e[pos : end+1].Clone()
 	return
 }
+
+// Children returns the positions of the root nodes of each argument of the node at pos
+func (e Expr) Children(pos int) []int {
+	children := make([]int, e[pos].Arity())
+	child := pos + 1
+	for i := range children {
+		children[i] = child
+		child = e.Traverse(child, nil, nil) + 1
+	}
+	return children
+}
+
+// Subtree returns a copy of the nodes of the subtree rooted at pos
+func (e Expr) Subtree(pos int) Expr {
+	end := e.Traverse(pos, nil, nil)
+	return e[pos : end+1].Clone()
+}
+
+// SubstituteVariables returns a copy of the expression with every variable
+// replaced by the expression f returns for its index, if not nil
+func (e Expr) SubstituteVariables(f func(narg int) Expr) Expr {
+	out := Expr{}
+	for _, op := range e {
+		if n, ok := VariableIndex(op); ok {
+			if sub := f(n); sub != nil {
+				out = append(out, sub...)
+				continue
+			}
+		}
+		out = append(out, op)
+	}
+	return out
+}
@@ -1,6 +1,8 @@
 package operator
 
 import (
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -24,6 +26,12 @@ func (f *BaseFunc) Format(args ...string) string {
     }
 }
 
+// Function returns an opcode that represents a function with the given arity,
+// formatted as name(args...)
+func Function(name string, arity int) Opcode {
+    return &BaseFunc{name, arity}
+}
+
 // binary operator type
 type binOp struct{ *BaseFunc }
 
@@ -49,31 +57,68 @@ func Variable(name string, narg int) Opcode {
 
 func (v variable) Eval(input ...float64) float64 { return input[v.Narg] }
 
+// VariableIndex returns the index of the input op refers to if it is a variable
+func VariableIndex(op Opcode) (int, bool) {
+    v, ok := op.(variable)
+    return v.Narg, ok
+}
+
+// constant type
+type constant struct {
+    *BaseFunc
+    Value float64
+}
+
+// Constant returns an opcode that represents a numeric constant (a leaf in the tree)
+func Constant(value float64) Opcode {
+    return constant{&BaseFunc{strconv.FormatFloat(value, 'g', -1, 64), 0}, value}
+}
+
+func (c constant) Eval(input ...float64) float64 { return c.Value }
+
+// ConstantValue returns the value of op if it is a constant
+func ConstantValue(op Opcode) (float64, bool) {
+    c, ok := op.(constant)
+    return c.Value, ok
+}
+
 const ZEROISH = 1e-10
 
 // numOp defines a numeric binary operator type
 type numOp struct {
 	Opcode
 	fun func(a, b float64) float64
+	commutative bool
 }
 
 func (o numOp) Eval(args ...float64) float64 {
 	return o.fun(args[0], args[1])
 }
 
+func (o numOp) Commutative() bool { return o.commutative }
+
+// IsCommutative reports whether the order of op's arguments doesn't change its result
+func IsCommutative(op Opcode) bool {
+    c, ok := op.(interface{ Commutative() bool })
+    return ok && c.Commutative()
+}
+
 var Add numOp = numOp{
     Operator("+"),
     func(a, b float64) float64 { return a + b },
+    true,
 }
 
 var Sub numOp = numOp{
     Operator("-"),
     func(a, b float64) float64 { return a - b },
+    false,
 }
  
 var Mul numOp = numOp{
     Operator("*"),
     func(a, b float64) float64 { return a * b },
+    true,
 }
 
 var Div numOp = numOp{
@@ -84,4 +129,20 @@ var Div numOp = numOp{
         }
         return a / b
     },
+    false,
+}
+
+// unOp defines a numeric unary function type
+type unOp struct {
+	Opcode
+	fun func(a float64) float64
+}
+
+func (o unOp) Eval(args ...float64) float64 {
+	return o.fun(args[0])
+}
+
+var Logistic unOp = unOp{
+    Function("sig", 1),
+    func(a float64) float64 { return 1 / (1 + math.Exp(-a)) },
 }
@@ -2,16 +2,21 @@ package operator
 
 import (
 	"fmt"
+	"math/rand"
 	"strconv"
 )
 
 // OpSet represents the set of all available functions/variables.
 // NumVars is the number of input variables, Terminals a list of all the variables
-// and Primitives are the operators 
+// and Primitives are the operators.
+// If Ephemeral is true, random constants in [ConstMin, ConstMax] are also used as terminals
 type OpSet struct {
 	NumVars    int
 	Terminals  []Opcode
 	Primitives []Opcode
+	Ephemeral  bool
+	ConstMin   float64
+	ConstMax   float64
 }
 
 // CreateOpSet returns a set of available operators (Add, Sub, Mul and Div)
@@ -45,6 +50,43 @@ func (pset *OpSet) String() string {
 	return fmt.Sprint(ops)
 }
 
+// AddEphemeral enables ephemeral random constants uniformly drawn from [min, max]
+func (pset *OpSet) AddEphemeral(min, max float64) {
+	pset.Ephemeral = true
+	pset.ConstMin, pset.ConstMax = min, max
+}
+
+// NumTerminals returns the number of terminal choices, counting ephemeral constants as one
+func (pset *OpSet) NumTerminals() int {
+	if pset.Ephemeral {
+		return len(pset.Terminals) + 1
+	}
+	return len(pset.Terminals)
+}
+
+// RandomTerminal returns a random variable or, if enabled, a new ephemeral constant, drawn with rng
+func (pset *OpSet) RandomTerminal(rng *rand.Rand) Opcode {
+	n := rng.Intn(pset.NumTerminals())
+	if n == len(pset.Terminals) {
+		return Constant(pset.ConstMin + rng.Float64()*(pset.ConstMax-pset.ConstMin))
+	}
+	return pset.Terminals[n]
+}
+
+// RandomPrimitive returns a random primitive with the given arity, drawn with rng, or nil if there's none
+func (pset *OpSet) RandomPrimitive(arity int, rng *rand.Rand) Opcode {
+	candidates := []Opcode{}
+	for _, op := range pset.Primitives {
+		if op.Arity() == arity {
+			candidates = append(candidates, op)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rng.Intn(len(candidates))]
+}
+
 // Var returns the nth variable
 func (pset *OpSet) Var(n int) Opcode {
 	return pset.Terminals[n]
@@ -0,0 +1,174 @@
+package pop
+
+import (
+    "fmt"
+    "math"
+    "math/rand"
+)
+
+// Acceptance is an interface for deciding whether the children produced by a
+// variation replace their parents. children[i] is always compared to parents[i]
+type Acceptance interface {
+    Accept(parents, children Population) Population
+    String() string
+}
+
+// Scheduled is implemented by operators whose parameters change along the generations
+type Scheduled interface {
+    NextGeneration()
+}
+
+// acceptBase defines the base structure to be embedded by the acceptance policies
+// that need to evaluate the children
+type acceptBase struct {
+    evaluator Evaluator
+}
+
+func newAcceptBase(e Evaluator) acceptBase {
+    return acceptBase{evaluator: e}
+}
+
+// evaluate calculates the fitness of ind if it isn't valid yet
+func (a acceptBase) evaluate(ind *Individual) {
+    if ind.FitnessValid {
+        return
+    }
+    ind.Fitness, ind.FitnessValid = fitness(a.evaluator, ind)
+}
+
+// better reports whether x is better than y. Individuals with invalid fitness are never better
+func (a acceptBase) better(x, y *Individual) bool {
+    if !validFitness(x) {
+        return false
+    }
+    return !validFitness(y) || a.evaluator.CompareFitness(x.Fitness, y.Fitness)
+}
+
+// alwaysAccept defines the classic GP policy, where children always replace their parents
+type alwaysAccept struct{}
+
+// AlwaysAccept returns a policy where children always replace their parents.
+// Children are not evaluated, which is left to Population.Evaluate
+func AlwaysAccept() Acceptance {
+    return alwaysAccept{}
+}
+
+func (a alwaysAccept) String() string {
+    return "AlwaysAccept"
+}
+
+func (a alwaysAccept) Accept(parents, children Population) Population {
+    return children
+}
+
+// greedy defines a policy where children only replace their parents if they're better
+type greedy struct {
+    acceptBase
+}
+
+// GreedyAcceptance returns a policy where a child only replaces its parent if
+// its fitness is better, according to e, than the parent's fitness
+func GreedyAcceptance(e Evaluator) Acceptance {
+    return greedy{newAcceptBase(e)}
+}
+
+func (a greedy) String() string {
+    return "GreedyAcceptance"
+}
+
+func (a greedy) Accept(parents, children Population) Population {
+    for i := range children {
+        if children[i] == parents[i] {
+            continue
+        }
+        a.evaluate(children[i])
+        if !a.better(children[i], parents[i]) {
+            children[i] = parents[i]
+        }
+    }
+    return children
+}
+
+// annealing defines a simulated annealing like acceptance policy
+type annealing struct {
+    acceptBase
+    temperature *float64
+    cooling     float64
+    rng         *rand.Rand
+}
+
+// AnnealingAcceptance returns a policy where better children always replace their
+// parents, and worse children replace them with probability exp(-d/T), d being
+// the fitness difference between them. T starts at temp and is multiplied by
+// cooling at every generation. The chance is drawn with rng
+func AnnealingAcceptance(e Evaluator, temp, cooling float64, rng *rand.Rand) Acceptance {
+    return annealing{newAcceptBase(e), &temp, cooling, rng}
+}
+
+func (a annealing) String() string {
+    return fmt.Sprintf("AnnealingAcceptance(%.3f)", *a.temperature)
+}
+
+func (a annealing) NextGeneration() {
+    *a.temperature *= a.cooling
+    if *a.temperature < minTemperature {
+        *a.temperature = minTemperature
+    }
+}
+
+func (a annealing) Accept(parents, children Population) Population {
+    for i := range children {
+        if children[i] == parents[i] {
+            continue
+        }
+        a.evaluate(children[i])
+        if a.better(children[i], parents[i]) {
+            continue
+        }
+        if !validFitness(children[i]) {
+            children[i] = parents[i]
+            continue
+        }
+        diff := math.Abs(children[i].Fitness - parents[i].Fitness)
+        if a.rng.Float64() >= math.Exp(-diff / *a.temperature) {
+            children[i] = parents[i]
+        }
+    }
+    return children
+}
+
+// offspringTournament defines a policy where parent and child compete in a tournament
+type offspringTournament struct {
+    acceptBase
+    prob float64
+    rng  *rand.Rand
+}
+
+// TournamentAcceptance returns a policy where each child competes with its parent
+// and the better of them survives with probability prob, drawn with rng, otherwise the worse one survives
+func TournamentAcceptance(e Evaluator, prob float64, rng *rand.Rand) Acceptance {
+    return offspringTournament{newAcceptBase(e), prob, rng}
+}
+
+func (a offspringTournament) String() string {
+    return fmt.Sprintf("TournamentAcceptance(%.2f)", a.prob)
+}
+
+func (a offspringTournament) Accept(parents, children Population) Population {
+    for i := range children {
+        if children[i] == parents[i] {
+            continue
+        }
+        a.evaluate(children[i])
+        winner, loser := parents[i], children[i]
+        if a.better(children[i], parents[i]) {
+            winner, loser = loser, winner
+        }
+        if a.rng.Float64() < a.prob {
+            children[i] = winner
+        } else {
+            children[i] = loser
+        }
+    }
+    return children
+}
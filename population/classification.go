@@ -0,0 +1,194 @@
+package pop
+
+import (
+	"math"
+
+	dataset "github.com/franciscobonand/symb-regr-gp/datasets"
+	"github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// Classification metrics
+const (
+    // AccuracyMetric is the weighted fraction of rows of the right class (higher is better)
+    AccuracyMetric = "accuracy"
+    // BalancedAccuracyMetric is the mean recall of the classes (higher is better)
+    BalancedAccuracyMetric = "balanced"
+    // LogLossMetric is the cross-entropy of the class probabilities (lower is better)
+    LogLossMetric = "logloss"
+    // F1Metric is the F1 score of class 1 with two classes, or the mean F1 score
+    // of the classes with more than two (higher is better)
+    F1Metric = "f1"
+)
+
+// threshold returns the output value above which trees predict classes greater
+// than k. Thresholds are one unit apart and centered at zero
+func threshold(k, classes int) float64 {
+    return float64(k) + 1 - float64(classes)/2
+}
+
+// ClassOf returns the class, from 0 to classes-1, of the output of a tree.
+// With two classes, outputs of at least 0, whose sigmoid is at least 0.5, are
+// of class 1. With more, the classes are ordered along the output, separated by
+// a threshold at every unit (e.g. -0.5 and 0.5 with three classes)
+func ClassOf(out float64, classes int) int {
+    c := 0
+    for c < classes-1 && out >= threshold(c, classes) {
+        c++
+    }
+    return c
+}
+
+// ClassProbability returns the probability of the output of a tree being of
+// the given class in a cumulative logit model of the thresholds of ClassOf,
+// where the probability of a class greater than k is sigmoid(out - threshold(k)).
+// With two classes, the probability of class 1 is sigmoid(out)
+func ClassProbability(out float64, class, classes int) float64 {
+    greater := func(k int) float64 {
+        if k < 0 {
+            return 1
+        }
+        if k >= classes-1 {
+            return 0
+        }
+        return operator.Logistic.Eval(out - threshold(k, classes))
+    }
+    return greater(class - 1) - greater(class)
+}
+
+// ConfusionMatrix returns the weighted number of rows of ds of each class (rows
+// of the matrix) predicted as each class (columns), given the outputs of a tree on ds
+func ConfusionMatrix(outputs []float64, ds *dataset.Dataset, classes int) [][]float64 {
+    m := make([][]float64, classes)
+    for c := range m {
+        m[c] = make([]float64, classes)
+    }
+    for i, out := range outputs {
+        m[int(ds.Output[i])][ClassOf(out, classes)] += ds.Weight(i)
+    }
+    return m
+}
+
+// classification defines the evaluators of trees whose outputs are classes
+type classification struct {
+    ds      *dataset.Dataset
+    classes int
+    metric  string
+}
+
+// ClassificationEvaluator returns an evaluator of the given metric for trees
+// classifying the rows of ds, whose outputs are labels from 0 to classes-1.
+// Outputs are mapped to classes by ClassOf and to probabilities by ClassProbability
+func ClassificationEvaluator(ds *dataset.Dataset, classes int, metric string) Evaluator {
+    return classification{ds, classes, metric}
+}
+
+func (e classification) GetFitness(code operator.Expr) (float64, bool) {
+    return e.SemanticFitness(code.EvalColumns(e.ds.InputColumns(), e.ds.Rows()))
+}
+
+func (e classification) SemanticFitness(semantics []float64) (float64, bool) {
+    if len(semantics) == 0 {
+        return -1, false
+    }
+    if e.metric == LogLossMetric {
+        var acc, total float64
+        for i, out := range semantics {
+            p := ClassProbability(out, int(e.ds.Output[i]), e.classes)
+            w := e.ds.Weight(i)
+            acc -= w * math.Log(math.Max(p, 1e-15))
+            total += w
+        }
+        if total <= 0 || math.IsNaN(acc) {
+            return -1, false
+        }
+        return acc / total, true
+    }
+
+    m := ConfusionMatrix(semantics, e.ds, e.classes)
+    var right, total float64
+    for c := range m {
+        right += m[c][c]
+        for _, n := range m[c] {
+            total += n
+        }
+    }
+    if total <= 0 {
+        return -1, false
+    }
+    switch e.metric {
+    case BalancedAccuracyMetric:
+        var recall, present float64
+        for c := range m {
+            var support float64
+            for _, n := range m[c] {
+                support += n
+            }
+            if support > 0 {
+                recall += m[c][c] / support
+                present++
+            }
+        }
+        return recall / present, true
+    case F1Metric:
+        if e.classes == 2 {
+            return f1(m, 1), true
+        }
+        var sum float64
+        for c := range m {
+            sum += f1(m, c)
+        }
+        return sum / float64(e.classes), true
+    }
+    return right / total, true
+}
+
+// f1 returns the F1 score of a class from the confusion matrix.
+// It's 1 if the class is neither present nor predicted
+func f1(m [][]float64, class int) float64 {
+    tp := m[class][class]
+    var fp, fn float64
+    for c := range m {
+        if c != class {
+            fp += m[c][class]
+            fn += m[class][c]
+        }
+    }
+    if tp + fp + fn == 0 {
+        return 1
+    }
+    return 2 * tp / (2 * tp + fp + fn)
+}
+
+func (e classification) CompareFitness(a, b float64) bool {
+    if e.metric == LogLossMetric {
+        return a < b
+    }
+    return a > b
+}
+
+// argMax is a function of one argument per class that returns the output
+// decoded by ClassOf as the class of its greatest argument
+type argMax struct {
+    *operator.BaseFunc
+}
+
+func (f argMax) Eval(args ...float64) float64 {
+    best := 0
+    for i, v := range args {
+        if v > args[best] {
+            best = i
+        }
+    }
+    // the middle point between the thresholds of the class
+    return float64(best) + 0.5 - float64(len(args))/2
+}
+
+// OneVsRest returns the tree that classifies rows as the class whose tree has
+// the greatest output, trees[c] being trained to tell class c from the others
+func OneVsRest(trees []operator.Expr) operator.Expr {
+    code := operator.Expr{argMax{&operator.BaseFunc{OpName: "argmax", OpArity: len(trees)}}}
+    for _, tree := range trees {
+        code = append(code, tree...)
+    }
+    return code
+}
@@ -0,0 +1,102 @@
+package pop
+
+import (
+    "sync/atomic"
+
+    "github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// EvalCount holds the amount of work done evaluating individuals.
+// Fitness is the number of fitness evaluations, Rows the number of dataset rows
+// used by them and Nodes the number of tree nodes evaluated
+type EvalCount struct {
+    Fitness, Rows, Nodes int64
+}
+
+// Sub returns the difference between two counts
+func (c EvalCount) Sub(o EvalCount) EvalCount {
+    return EvalCount{c.Fitness - o.Fitness, c.Rows - o.Rows, c.Nodes - o.Nodes}
+}
+
+// EvalCounter is a thread-safe accumulator of evaluations, which can be shared by many evaluators
+type EvalCounter struct {
+    fitness, rows, nodes int64
+}
+
+// Add accounts a fitness evaluation of code on the given number of rows
+func (c *EvalCounter) Add(code operator.Expr, rows int) {
+    c.add(len(code), rows)
+}
+
+// add accounts an evaluation of a tree of the given size on the given number of rows
+func (c *EvalCounter) add(size, rows int) {
+    atomic.AddInt64(&c.fitness, 1)
+    atomic.AddInt64(&c.rows, int64(rows))
+    atomic.AddInt64(&c.nodes, int64(rows*size))
+}
+
+// addSemantic accounts a fitness evaluation done from the semantics of an individual,
+// which uses the given number of rows but evaluates no tree nodes
+func (c *EvalCounter) addSemantic(rows int) {
+    atomic.AddInt64(&c.fitness, 1)
+    atomic.AddInt64(&c.rows, int64(rows))
+}
+
+// Load returns the evaluations accounted so far
+func (c *EvalCounter) Load() EvalCount {
+    return EvalCount{
+        Fitness: atomic.LoadInt64(&c.fitness),
+        Rows: atomic.LoadInt64(&c.rows),
+        Nodes: atomic.LoadInt64(&c.nodes),
+    }
+}
+
+// counting is an Evaluator decorator that accounts every fitness evaluation
+type counting struct {
+    Evaluator
+    rows    int
+    counter *EvalCounter
+}
+
+// rowCounter is implemented by evaluators whose number of rows changes along the generations
+type rowCounter interface {
+    Rows() int
+}
+
+// CountingEvaluator returns an evaluator that delegates to e and accounts every
+// evaluation in counter. rows is the number of dataset rows used by e, unless
+// e tells its current number of rows
+func CountingEvaluator(e Evaluator, rows int, counter *EvalCounter) Evaluator {
+    return counting{e, rows, counter}
+}
+
+func (e counting) GetFitness(code operator.Expr) (float64, bool) {
+    rows := e.rows
+    if rc, ok := e.Evaluator.(rowCounter); ok {
+        rows = rc.Rows()
+    }
+    e.counter.Add(code, rows)
+    return e.Evaluator.GetFitness(code)
+}
+
+func (e counting) SemanticFitness(semantics []float64) (float64, bool) {
+    e.counter.addSemantic(len(semantics))
+    return e.Evaluator.(SemanticEvaluator).SemanticFitness(semantics)
+}
+
+// countEvaluation accounts an evaluation of a tree of the given size on the given
+// number of rows done outside of e, such as by variation operators, if e is a counting evaluator
+func countEvaluation(e Evaluator, size, rows int) {
+    if c, ok := e.(counting); ok {
+        c.counter.add(size, rows)
+    }
+}
+
+// countAs wraps inner so its evaluations are accounted in the same counter as e,
+// if e is a counting evaluator. Otherwise, inner is returned as is
+func countAs(e Evaluator, inner Evaluator, rows int) Evaluator {
+    if c, ok := e.(counting); ok {
+        return CountingEvaluator(inner, rows, c.counter)
+    }
+    return inner
+}
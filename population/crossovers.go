@@ -0,0 +1,212 @@
+package pop
+
+import (
+    "fmt"
+    "math"
+    "math/rand"
+
+    dataset "github.com/franciscobonand/symb-regr-gp/datasets"
+    "github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// semanticTries is the number of swaps tried by the semantic crossover before giving up
+const semanticTries = 10
+
+// subtreeSizes returns the size of the subtree rooted at each position of the tree
+func subtreeSizes(e operator.Expr) []int {
+    sizes := make([]int, len(e))
+    for pos := range e {
+        sizes[pos] = e.Traverse(pos, nil, nil) - pos + 1
+    }
+    return sizes
+}
+
+// nodeDepths returns the depth of each node of the tree
+func nodeDepths(e operator.Expr) []int {
+    depths := make([]int, len(e))
+    for pos := range e {
+        for _, child := range e.Children(pos) {
+            depths[child] = depths[pos] + 1
+        }
+    }
+    return depths
+}
+
+// swapSubtrees returns the children of exchanging the subtree at pos1 of a with the subtree at pos2 of b
+func swapSubtrees(a, b operator.Expr, pos1, pos2 int) (operator.Expr, operator.Expr) {
+    sub1, sub2 := a.Subtree(pos1), b.Subtree(pos2)
+    return a.Clone().ReplaceSubtree(pos1, sub2), b.Clone().ReplaceSubtree(pos2, sub1)
+}
+
+// fairCrossover picks a random crossover point in the first parent and uses
+// choose to pick the point of the second parent among the candidates whose
+// subtree size is at most 1 + 2 * size of the first parent's subtree
+func fairCrossover(rng *rand.Rand, acc Acceptance, name string, choose func(a, b operator.Expr, pos1 int, candidates []int) int) Variation {
+    cross := func(ind Population) Population {
+        if ind[0].Size() < 2 || ind[1].Size() < 2 {
+            return ind
+        }
+        a, b := ind[0].Code, ind[1].Code
+        pos1 := rng.Intn(len(a))
+        size1 := a.Traverse(pos1, nil, nil) - pos1 + 1
+        candidates := []int{}
+        for pos, size := range subtreeSizes(b) {
+            if size <= 1 + 2*size1 {
+                candidates = append(candidates, pos)
+            }
+        }
+        pos2 := choose(a, b, pos1, candidates)
+        child1, child2 := swapSubtrees(a, b, pos1, pos2)
+        ind[0] = Create(child1)
+        ind[1] = Create(child2)
+        return ind
+    }
+    return &variation{cross, name, acc}
+}
+
+// SizeFairCrossoverOp returns a crossover variation where the subtree taken from
+// the second parent is at most 1 + 2 times the size of the one removed from the
+// first parent, which prevents children from growing too fast
+func SizeFairCrossoverOp(rng *rand.Rand, acc Acceptance) Variation {
+    return fairCrossover(rng, acc, "SizeFairCrossover", func(a, b operator.Expr, pos1 int, candidates []int) int {
+        return candidates[rng.Intn(len(candidates))]
+    })
+}
+
+// HomologousCrossoverOp returns a size-fair crossover variation where, among the
+// candidate subtrees of the second parent, the one with the closest size to the
+// first parent's subtree is chosen. Ties are broken by the closest depth
+func HomologousCrossoverOp(rng *rand.Rand, acc Acceptance) Variation {
+    return fairCrossover(rng, acc, "HomologousCrossover", func(a, b operator.Expr, pos1 int, candidates []int) int {
+        size1 := a.Traverse(pos1, nil, nil) - pos1 + 1
+        depth1 := nodeDepths(a)[pos1]
+        sizes, depths := subtreeSizes(b), nodeDepths(b)
+        best, bestSize, bestDepth := -1, 0, 0
+        for _, pos := range candidates {
+            dsize := absInt(sizes[pos] - size1)
+            ddepth := absInt(depths[pos] - depth1)
+            if best < 0 || dsize < bestSize || (dsize == bestSize && ddepth < bestDepth) {
+                best, bestSize, bestDepth = pos, dsize, ddepth
+            }
+        }
+        return best
+    })
+}
+
+func absInt(n int) int {
+    if n < 0 {
+        return -n
+    }
+    return n
+}
+
+// commonRegion returns the pairs of positions of both trees that belong to their
+// common region, which is the set of nodes reached by walking both trees from the
+// root while the nodes have the same arity
+func commonRegion(a, b operator.Expr) [][2]int {
+    region := [][2]int{}
+    var walk func(pa, pb int)
+    walk = func(pa, pb int) {
+        region = append(region, [2]int{pa, pb})
+        if a[pa].Arity() != b[pb].Arity() {
+            return
+        }
+        ca, cb := a.Children(pa), b.Children(pb)
+        for i := range ca {
+            walk(ca[i], cb[i])
+        }
+    }
+    walk(0, 0)
+    return region
+}
+
+// OnePointCrossoverOp returns a crossover variation that swaps the subtrees
+// rooted at a random point of the common region of both parents, so the
+// exchanged subtrees are in the same position of both trees
+func OnePointCrossoverOp(rng *rand.Rand, acc Acceptance) Variation {
+    cross := func(ind Population) Population {
+        region := commonRegion(ind[0].Code, ind[1].Code)
+        point := region[rng.Intn(len(region))]
+        child1, child2 := swapSubtrees(ind[0].Code, ind[1].Code, point[0], point[1])
+        ind[0] = Create(child1)
+        ind[1] = Create(child2)
+        return ind
+    }
+    return &variation{cross, "OnePointCrossover", acc}
+}
+
+// UniformCrossoverOp returns a crossover variation that walks the common region
+// of both parents swapping each node with probability 0.5. Nodes inside the
+// region only have their opcodes swapped, while nodes at its boundary (different
+// arities) have their whole subtrees swapped
+func UniformCrossoverOp(rng *rand.Rand, acc Acceptance) Variation {
+    cross := func(ind Population) Population {
+        a, b := ind[0].Code, ind[1].Code
+        var walk func(pa, pb int) (operator.Expr, operator.Expr)
+        walk = func(pa, pb int) (operator.Expr, operator.Expr) {
+            swap := rng.Float64() < 0.5
+            if a[pa].Arity() != b[pb].Arity() {
+                sa, sb := a.Subtree(pa), b.Subtree(pb)
+                if swap {
+                    return sb, sa
+                }
+                return sa, sb
+            }
+            opa, opb := a[pa], b[pb]
+            if swap {
+                opa, opb = opb, opa
+            }
+            ca, cb := operator.Expr{opa}, operator.Expr{opb}
+            childrenb := b.Children(pb)
+            for i, childa := range a.Children(pa) {
+                sa, sb := walk(childa, childrenb[i])
+                ca, cb = append(ca, sa...), append(cb, sb...)
+            }
+            return ca, cb
+        }
+        child1, child2 := walk(0, 0)
+        ind[0] = Create(child1)
+        ind[1] = Create(child2)
+        return ind
+    }
+    return &variation{cross, "UniformCrossover", acc}
+}
+
+// SemanticCrossoverOp returns a subtree crossover variation that rejects swaps
+// whose subtrees are semantically equivalent, that is, their outputs on every row
+// of ds differ by less than eps, since those swaps produce children semantically
+// identical to their parents. If no valid swap is found after a few tries, the
+// parents are kept. The evaluations of the subtrees are accounted as the ones of e
+func SemanticCrossoverOp(ds *dataset.Dataset, eps float64, e Evaluator, rng *rand.Rand, acc Acceptance) Variation {
+    equivalent := func(sub1, sub2 operator.Expr) bool {
+        out1 := sub1.EvalColumns(ds.InputColumns(), ds.Rows())
+        out2 := sub2.EvalColumns(ds.InputColumns(), ds.Rows())
+        countEvaluation(e, len(sub1), ds.Rows())
+        countEvaluation(e, len(sub2), ds.Rows())
+        for i, a := range out1 {
+            b := out2[i]
+            // subtrees are distinct where only one of them is undefined
+            if math.IsNaN(a) != math.IsNaN(b) || math.Abs(a - b) >= eps {
+                return false
+            }
+        }
+        return true
+    }
+    cross := func(ind Population) Population {
+        if ind[0].Size() < 2 || ind[1].Size() < 2 {
+            return ind
+        }
+        for try := 0; try < semanticTries; try++ {
+            pos1, subtree1 := ind[0].Code.RandomSubtree(rng)
+            pos2, subtree2 := ind[1].Code.RandomSubtree(rng)
+            if equivalent(subtree1, subtree2) {
+                continue
+            }
+            ind[0] = Create(ind[0].Code.Clone().ReplaceSubtree(pos1, subtree2))
+            ind[1] = Create(ind[1].Code.Clone().ReplaceSubtree(pos2, subtree1))
+            break
+        }
+        return ind
+    }
+    return &variation{cross, fmt.Sprintf("SemanticCrossover(%g)", eps), acc}
+}
@@ -0,0 +1,215 @@
+package pop
+
+import (
+    "math"
+    "math/rand"
+
+    dataset "github.com/franciscobonand/symb-regr-gp/datasets"
+    "github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// ConstantName is the name under which all constants are counted in the opcode frequencies
+const ConstantName = "const"
+
+// fitnessBins is the number of bins used to calculate the fitness entropy
+const fitnessBins = 10
+
+// Diversity holds genotypic, phenotypic and fitness diversity measures of a population.
+// DistinctSubtrees is the number of distinct subtrees among all individuals,
+// EditDistance the mean tree edit distance between pairs of a sample of individuals,
+// SemanticVariance the mean over the dataset rows of the variance of the individuals'
+// outputs, FitnessEntropy the Shannon entropy (in bits) of the fitness distribution
+// and Frequencies the relative frequency of each opcode in the population
+type Diversity struct {
+    DistinctSubtrees, EditDistance, SemanticVariance, FitnessEntropy float64
+    Frequencies map[string]float64
+}
+
+// genome returns the individual's tree, unless it is a geometric semantic tree too big to be built
+func genome(ind *Individual) (operator.Expr, bool) {
+    if ind.lineage != nil && ind.lineage.size > maxFormatSize {
+        return nil, false
+    }
+    return ind.Expr(), true
+}
+
+// opcodeName returns the name under which op is counted in the opcode frequencies
+func opcodeName(op operator.Opcode) string {
+    if _, ok := operator.ConstantValue(op); ok {
+        return ConstantName
+    }
+    return op.String()
+}
+
+// GetDiversity returns the diversity measures of a population.
+// The semantic variance is calculated on the rows of ds, and the edit distance
+// on up to sample random individuals
+func (pop Population) GetDiversity(ds *dataset.Dataset, sample int) Diversity {
+    d := Diversity{Frequencies: map[string]float64{}}
+    subtrees := map[string]bool{}
+    genomes := []operator.Expr{}
+    total := 0.0
+    for _, ind := range pop {
+        code, ok := genome(ind)
+        if !ok {
+            continue
+        }
+        genomes = append(genomes, code)
+        for _, str := range subtreeStrings(code) {
+            subtrees[str] = true
+        }
+        for _, op := range code {
+            d.Frequencies[opcodeName(op)]++
+            total++
+        }
+    }
+    for name := range d.Frequencies {
+        d.Frequencies[name] /= total
+    }
+    d.DistinctSubtrees = float64(len(subtrees))
+    d.EditDistance = meanEditDistance(genomes, sample)
+    d.SemanticVariance = pop.semanticVariance(ds)
+    d.FitnessEntropy = pop.fitnessEntropy()
+    return d
+}
+
+// subtreeStrings returns the infix representation of every subtree of the expression
+func subtreeStrings(e operator.Expr) []string {
+    strs := make([]string, 0, len(e))
+    list := []string{}
+    node := func(op operator.Opcode) {
+        end := len(list) - op.Arity()
+        list = append(list[:end], op.Format(list[end:]...))
+        strs = append(strs, list[len(list)-1])
+    }
+    term := func(op operator.Opcode) {
+        list = append(list, op.Format())
+        strs = append(strs, list[len(list)-1])
+    }
+    e.Traverse(0, node, term)
+    return strs
+}
+
+// meanEditDistance returns the mean edit distance between every pair of up to sample random trees
+func meanEditDistance(genomes []operator.Expr, sample int) float64 {
+    rand.Shuffle(len(genomes), func(i, j int) { genomes[i], genomes[j] = genomes[j], genomes[i] })
+    if len(genomes) > sample {
+        genomes = genomes[:sample]
+    }
+    acc, pairs := 0.0, 0.0
+    for i := range genomes {
+        for j := i + 1; j < len(genomes); j++ {
+            acc += float64(editDistance(genomes[i], genomes[j], 0, 0))
+            pairs++
+        }
+    }
+    if pairs == 0 {
+        return 0
+    }
+    return acc / pairs
+}
+
+// editDistance returns the top-down (Selkow) tree edit distance between the
+// subtrees at pa and pb, with unit costs: relabeling a node costs 1 and
+// inserting or deleting a subtree costs its size
+func editDistance(a, b operator.Expr, pa, pb int) int {
+    cost := 0
+    if a[pa].String() != b[pb].String() {
+        cost = 1
+    }
+    ca, cb := a.Children(pa), b.Children(pb)
+    size := func(e operator.Expr, pos int) int { return e.Traverse(pos, nil, nil) - pos + 1 }
+    // dist[i][j] is the distance between the first i children of a and the first j of b
+    dist := make([][]int, len(ca)+1)
+    for i := range dist {
+        dist[i] = make([]int, len(cb)+1)
+        if i > 0 {
+            dist[i][0] = dist[i-1][0] + size(a, ca[i-1])
+        }
+    }
+    for j := 1; j <= len(cb); j++ {
+        dist[0][j] = dist[0][j-1] + size(b, cb[j-1])
+        for i := 1; i <= len(ca); i++ {
+            del := dist[i-1][j] + size(a, ca[i-1])
+            ins := dist[i][j-1] + size(b, cb[j-1])
+            sub := dist[i-1][j-1] + editDistance(a, b, ca[i-1], cb[j-1])
+            dist[i][j] = minInt(del, ins, sub)
+        }
+    }
+    return cost + dist[len(ca)][len(cb)]
+}
+
+func minInt(nums ...int) int {
+    min := nums[0]
+    for _, n := range nums[1:] {
+        if n < min {
+            min = n
+        }
+    }
+    return min
+}
+
+// semanticVariance returns the mean over the rows of ds of the variance of the
+// individuals' outputs. Non-finite outputs are ignored
+func (pop Population) semanticVariance(ds *dataset.Dataset) float64 {
+    if ds.Rows() == 0 {
+        return 0
+    }
+    outputs := make([][]float64, len(pop))
+    for i, ind := range pop {
+        outputs[i] = ind.Semantics
+        if outputs[i] == nil {
+            outputs[i] = ind.Predict(ds)
+        }
+    }
+    acc := 0.0
+    for row := 0; row < ds.Rows(); row++ {
+        var sum, sumsq, n float64
+        for i := range pop {
+            out := outputs[i][row]
+            if math.IsNaN(out) || math.IsInf(out, 0) {
+                continue
+            }
+            sum += out
+            sumsq += out * out
+            n++
+        }
+        if n > 0 {
+            mean := sum / n
+            acc += math.Max(sumsq/n - mean*mean, 0)
+        }
+    }
+    return acc / float64(ds.Rows())
+}
+
+// fitnessEntropy returns the Shannon entropy of the valid fitness values,
+// grouped in equal width bins between the lowest and highest fitness
+func (pop Population) fitnessEntropy() float64 {
+    fits := []float64{}
+    low, high := math.Inf(1), math.Inf(-1)
+    for _, ind := range pop {
+        if validFitness(ind) {
+            fits = append(fits, ind.Fitness)
+            low, high = math.Min(low, ind.Fitness), math.Max(high, ind.Fitness)
+        }
+    }
+    if len(fits) == 0 || high == low {
+        return 0
+    }
+    bins := make([]float64, fitnessBins)
+    for _, f := range fits {
+        bin := int((f - low) / (high - low) * fitnessBins)
+        if bin == fitnessBins {
+            bin--
+        }
+        bins[bin]++
+    }
+    entropy := 0.0
+    for _, count := range bins {
+        if count > 0 {
+            p := count / float64(len(fits))
+            entropy -= p * math.Log2(p)
+        }
+    }
+    return entropy
+}
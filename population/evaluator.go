@@ -14,6 +14,34 @@ type Evaluator interface {
     CompareFitness(a, b float64) bool
 }
 
+// SemanticEvaluator is implemented by evaluators able to calculate the fitness
+// directly from the semantics (outputs on each training row) of an individual
+type SemanticEvaluator interface {
+    SemanticFitness(semantics []float64) (float64, bool)
+}
+
+// semanticEvaluator returns e as a SemanticEvaluator, if it supports semantics
+func semanticEvaluator(e Evaluator) (SemanticEvaluator, bool) {
+    if c, ok := e.(counting); ok {
+        if _, ok := c.Evaluator.(SemanticEvaluator); !ok {
+            return nil, false
+        }
+    }
+    se, ok := e.(SemanticEvaluator)
+    return se, ok
+}
+
+// fitness calculates the fitness of ind using e.
+// The individual's semantics are used instead of its genome whenever possible
+func fitness(e Evaluator, ind *Individual) (float64, bool) {
+    if ind.Semantics != nil {
+        if se, ok := semanticEvaluator(e); ok {
+            return se.SemanticFitness(ind.Semantics)
+        }
+    }
+    return e.GetFitness(ind.Expr())
+}
+
 // Evaluate calls the eval Evaluator to calculate the fitness for each individual.
 // The evaluation process can be done in parallel
 func (pop Population) Evaluate(eval Evaluator, threads int) (Population, int) {
@@ -39,7 +67,7 @@ func (pop Population) Evaluate(eval Evaluator, threads int) (Population, int) {
 		}
 		go func(indices []int) {
 			for _, i := range indices {
-				pop[i].Fitness, pop[i].FitnessValid = eval.GetFitness(pop[i].Code)
+				pop[i].Fitness, pop[i].FitnessValid = fitness(eval, pop[i])
 			}
 			wg.Done()
 		}(todo[start:end])
@@ -50,21 +78,27 @@ func (pop Population) Evaluate(eval Evaluator, threads int) (Population, int) {
 	return pop, evals
 }
 
-// RMSE defines the root mean squared error evaluator (fitness closer to 0.0 is better)
+// RMSE defines the root mean squared error evaluator (fitness closer to 0.0 is better).
+// Errors are weighted by the weights of the rows, if the dataset has them
 type RMSE struct {
     DS *dataset.Dataset
 }
 
 func (e RMSE) GetFitness(code operator.Expr) (float64, bool) {
-    var acc, count float64
-    for i, input := range e.DS.Input {
-        acc += math.Pow(code.Eval(input...) - e.DS.Output[i], 2)
-        count++
+    return e.SemanticFitness(code.EvalColumns(e.DS.InputColumns(), e.DS.Rows()))
+}
+
+func (e RMSE) SemanticFitness(semantics []float64) (float64, bool) {
+    var acc, total float64
+    for i, out := range semantics {
+        w := e.DS.Weight(i)
+        acc += w * math.Pow(out - e.DS.Output[i], 2)
+        total += w
     }
-    if count == 0.0 {
+    if len(semantics) == 0 || total <= 0 {
         return -1, false
     }
-    return math.Sqrt(acc / count), true
+    return math.Sqrt(acc / total), true
 }
 
 func (e RMSE) CompareFitness(a, b float64) bool {
@@ -17,6 +17,7 @@ type genBase struct {
     min, max  int
     condition func(height, depth int) bool
     name      string
+    rng       *rand.Rand
 }
 
 func (g genBase) String() string {
@@ -26,16 +27,15 @@ func (g genBase) String() string {
 // Generate defines the core logic of the generators
 func (g genBase) Generate() *Individual {
     code := operator.Expr{}
-    height := rand.Intn(1+g.max-g.min) + g.min
+    height := g.rng.Intn(1+g.max-g.min) + g.min
     stack := []int{0}
     depth := 0
     for len(stack) > 0 {
         depth, stack = stack[len(stack)-1], stack[:len(stack)-1]
         if g.condition(height, depth) {
-            op := randomOp(g.pset.Terminals)
-            code = append(code, op)
+            code = append(code, g.pset.RandomTerminal(g.rng))
         } else {
-            op := randomOp(g.pset.Primitives)
+            op := g.pset.Primitives[g.rng.Intn(len(g.pset.Primitives))]
             code = append(code, op)
             for i := 0; i < op.Arity(); i++ {
                 stack = append(stack, depth+1)
@@ -45,39 +45,44 @@ func (g genBase) Generate() *Individual {
     return &Individual{Code: code}
 }
 
-// NewGrowGenerator returns a generator to produce individuals with irregular expression trees
-func NewGrowGenerator(ops *operator.OpSet, min, max int) Generator {
-    terms, prims := len(ops.Terminals), len(ops.Primitives)
+// NewGrowGenerator returns a generator to produce individuals with irregular expression trees,
+// drawn with rng as every other random choice of this package
+func NewGrowGenerator(ops *operator.OpSet, min, max int, rng *rand.Rand) Generator {
+    terms, prims := ops.NumTerminals(), len(ops.Primitives)
     terminalRatio := float64(terms) / float64(terms+prims)
     return genBase{
         ops, min, max,
         func(height, depth int) bool {
-            return depth == height || (depth >= min && rand.Float64() < terminalRatio)
+            return depth == height || (depth >= min && rng.Float64() < terminalRatio)
         },
         "GrowGenerator",
+        rng,
     }
 }
 
 // NewFullGenerator returns a generator to produce individuals with balanced expression trees
-func NewFullGenerator(ops *operator.OpSet, min, max int) Generator {
+func NewFullGenerator(ops *operator.OpSet, min, max int, rng *rand.Rand) Generator {
     return genBase{
         ops, min, max,
         func(height, depth int) bool {
             return depth == height 
         },
         "FullGenerator",
+        rng,
     }
 }
 
 type rampedGenerator struct {
     grow, full Generator
+    rng        *rand.Rand
 }
 
 // NewRampedGenerator returns a Ramped population generator (combination of Grow and Full)
-func NewRampedGenerator(ops *operator.OpSet, min, max int) Generator {
+func NewRampedGenerator(ops *operator.OpSet, min, max int, rng *rand.Rand) Generator {
     return rampedGenerator{
-        NewGrowGenerator(ops, min, max),
-        NewFullGenerator(ops, min, max),
+        NewGrowGenerator(ops, min, max, rng),
+        NewFullGenerator(ops, min, max, rng),
+        rng,
     }
 }
 
@@ -86,12 +91,8 @@ func (rg rampedGenerator) String() string {
 }
 
 func (rg rampedGenerator) Generate() *Individual {
-    if rand.Float64() >= 0.5 {
+    if rg.rng.Float64() >= 0.5 {
         return rg.grow.Generate()
     }
     return rg.full.Generate()
 }
-
-func randomOp(list []operator.Opcode) operator.Opcode {
-    return list[rand.Intn(len(list))]
-}
@@ -13,10 +13,13 @@ type Variation interface {
 	String() string
 }
 
-// variation defines the base structure to be embedded by other genetic operators
+// variation defines the base structure to be embedded by other genetic operators.
+// vfunc produces the children, and acceptance decides whether they replace their parents.
+// Random choices of the operators are drawn from the rng given to their constructors
 type variation struct {
 	vfunc      func(in Population) (out Population)
 	name       string
+	acceptance Acceptance
 }
 
 func (v *variation) String() string {
@@ -31,76 +34,85 @@ func (v *variation) Variate(in Population) Population {
             out[i] = in[i] 
         }
     }
-    return out
+    return v.acceptance.Accept(in, out)
 }
 
-// MutationOp returns a mutation variation
-func MutationOp(gen Generator, eval Evaluator) Variation {
+// MutationOp returns a subtree mutation variation, which replaces a random
+// subtree with a newly generated one
+func MutationOp(gen Generator, rng *rand.Rand, acc Acceptance) Variation {
 	mutate := func(ind Population) Population {
 		tree := ind[0].Code.Clone()
-		pos := rand.Intn(len(tree))
+		pos := rng.Intn(len(tree))
 		newtree := gen.Generate().Code
-        newcode := tree.ReplaceSubtree(pos, newtree)
-        newfit, _ := eval.GetFitness(newcode)
-        if newfit < ind[0].Fitness {
-            ind[0] = Create(newcode)
-        }
+        ind[0] = Create(tree.ReplaceSubtree(pos, newtree))
 		return ind
 	}
-	return &variation{mutate, fmt.Sprintf("Mutation(%s)", gen)}
+	return &variation{mutate, fmt.Sprintf("Mutation(%s)", gen), acc}
 }
 
 // CrossoverOp returns a crossover variation
-func CrossoverOp(eval Evaluator) Variation {
+func CrossoverOp(rng *rand.Rand, acc Acceptance) Variation {
 	cross := func(ind Population) Population {
 		if ind[0].Size() < 2 || ind[1].Size() < 2 {
 			return ind
 		}
-		pos1, subtree1 := ind[0].Code.RandomSubtree()
-		pos2, subtree2 := ind[1].Code.RandomSubtree()
-        newcode := ind[0].Code.Clone().ReplaceSubtree(pos1, subtree2)
-        newfit, _ := eval.GetFitness(newcode)
-        if newfit < ind[0].Fitness {
-            ind[0] = Create(newcode)
-        }
-        newcode = ind[1].Code.Clone().ReplaceSubtree(pos2, subtree1)
-        newfit, _ = eval.GetFitness(newcode)
-        if newfit < ind[1].Fitness {
-            ind[1] = Create(newcode)
-        }
+		pos1, subtree1 := ind[0].Code.RandomSubtree(rng)
+		pos2, subtree2 := ind[1].Code.RandomSubtree(rng)
+        ind[0] = Create(ind[0].Code.Clone().ReplaceSubtree(pos1, subtree2))
+        ind[1] = Create(ind[1].Code.Clone().ReplaceSubtree(pos2, subtree1))
 		return ind
 	}
-	return &variation{cross, "Crossover"}
+	return &variation{cross, "Crossover", acc}
 }
 
-// ApplyGeneticOps applies crossover and/or mutation operators based on their probability.
-// Both operators can be applied in the same individual
-func ApplyGeneticOps(pop Population, cross, mutate Variation, cxProb, mutProb float64) (Population, float64, float64) {
-    var betterchild, worsechild float64
-    cxindivs := Population{}
-    totalfit := 0.0
+// ApplyGeneticOps applies crossover and/or mutation operators based on their probability,
+// drawn with rng. Both operators can be applied in the same individual.
+// It also returns the indices of the offspring produced by crossover, which
+// are compared to their parents by CompareChildren once they're evaluated
+func ApplyGeneticOps(pop Population, cross, mutate Variation, cxProb, mutProb float64, rng *rand.Rand) (Population, []int) {
+    cxindices := []int{}
 	offspring := pop.Clone()
 	for i := 1; i < len(pop); i += 2 {
-		if rand.Float64() < cxProb {
+		if rng.Float64() < cxProb {
 			children := cross.Variate(offspring[i-1 : i+1])
 			offspring[i-1], offspring[i] = children[0], children[1]
-            cxindivs = append(cxindivs, children...)
+            cxindices = append(cxindices, i-1, i)
 		}
 	}
 	for i := 0; i < len(pop); i++ {
-        totalfit += pop[i].Fitness
-		if rand.Float64() < mutProb {
+		if rng.Float64() < mutProb {
 			children := mutate.Variate(offspring[i : i+1])
 			offspring[i] = children[0]
 		}
 	}
-    meanParentFit := totalfit / float64(len(pop))
-    for _, ind := range cxindivs {
-        if ind.Fitness > meanParentFit {
+	return offspring, cxindices
+}
+
+// CompareChildren returns how many of the evaluated offspring of the given
+// indices are better and worse, according to e, than the mean fitness of the parents
+func CompareChildren(parents, offspring Population, indices []int, e Evaluator) (float64, float64) {
+    var betterchild, worsechild float64
+    totalfit, nvalid := 0.0, 0.0
+    for _, ind := range parents {
+        if validFitness(ind) {
+            totalfit += ind.Fitness
+            nvalid++
+        }
+    }
+    if nvalid == 0 {
+        return betterchild, worsechild
+    }
+    meanParentFit := totalfit / nvalid
+    for _, i := range indices {
+        ind := offspring[i]
+        if !validFitness(ind) {
+            continue
+        }
+        if e.CompareFitness(ind.Fitness, meanParentFit) {
             betterchild++
-        } else if ind.Fitness < meanParentFit {
+        } else if e.CompareFitness(meanParentFit, ind.Fitness) {
             worsechild++
         }
     }
-	return offspring, betterchild, worsechild
+    return betterchild, worsechild
 }
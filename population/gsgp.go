@@ -0,0 +1,185 @@
+package pop
+
+import (
+    "fmt"
+    "math"
+
+    dataset "github.com/franciscobonand/symb-regr-gp/datasets"
+    "github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// maxFormatSize is the maximum size of a geometric semantic tree to be built just for printing
+const maxFormatSize = 5000
+
+// lineage records how a geometric semantic individual was built from its parents.
+// Instead of materializing trees that grow exponentially along the generations,
+// each individual only keeps references to its parents and random trees.
+// Crossover children are T1*R + T2*(1-R), and mutation children are
+// T1 + step*(R1-R2), where every R is a random tree wrapped by the logistic function
+type lineage struct {
+    parents []*Individual
+    random  []operator.Expr
+    step    float64
+    size    int
+    depth   int
+}
+
+// crossoverLineage returns the lineage of t1*sig(r) + t2*(1-sig(r))
+func crossoverLineage(t1, t2 *Individual, r operator.Expr) *lineage {
+    // + * t1 sig r * t2 - 1 sig r
+    size := satAdd(satAdd(t1.Size(), t2.Size()), 2*len(r)+7)
+    depth := maxInt(t1.Depth()+2, t2.Depth()+2, r.Depth()+4)
+    return &lineage{[]*Individual{t1, t2}, []operator.Expr{r}, 0, size, depth}
+}
+
+// mutationLineage returns the lineage of t + step*(sig(r1) - sig(r2))
+func mutationLineage(t *Individual, r1, r2 operator.Expr, step float64) *lineage {
+    // + t * step - sig r1 sig r2
+    size := satAdd(t.Size(), len(r1)+len(r2)+6)
+    depth := maxInt(t.Depth()+1, r1.Depth()+4, r2.Depth()+4)
+    return &lineage{[]*Individual{t}, []operator.Expr{r1, r2}, step, size, depth}
+}
+
+// materialize builds the whole tree of the individual
+func (l *lineage) materialize() operator.Expr {
+    sig := operator.Logistic
+    if len(l.parents) == 2 {
+        code := operator.Expr{operator.Add, operator.Mul}
+        code = append(code, l.parents[0].Expr()...)
+        code = append(code, sig)
+        code = append(code, l.random[0]...)
+        code = append(code, operator.Mul)
+        code = append(code, l.parents[1].Expr()...)
+        code = append(code, operator.Sub, operator.Constant(1), sig)
+        return append(code, l.random[0]...)
+    }
+    code := operator.Expr{operator.Add}
+    code = append(code, l.parents[0].Expr()...)
+    code = append(code, operator.Mul, operator.Constant(l.step), operator.Sub, sig)
+    code = append(code, l.random[0]...)
+    code = append(code, sig)
+    return append(code, l.random[1]...)
+}
+
+// eval evaluates the individual for the given input values without building its tree.
+// Ancestors shared by many paths of the lineage are only evaluated once
+func (l *lineage) eval(input []float64, memo map[*lineage]float64) float64 {
+    if val, ok := memo[l]; ok {
+        return val
+    }
+    parent := func(ind *Individual) float64 {
+        if ind.lineage != nil {
+            return ind.lineage.eval(input, memo)
+        }
+        return ind.Code.Eval(input...)
+    }
+    var val float64
+    if len(l.parents) == 2 {
+        r := operator.Logistic.Eval(l.random[0].Eval(input...))
+        val = parent(l.parents[0])*r + parent(l.parents[1])*(1-r)
+    } else {
+        r1 := operator.Logistic.Eval(l.random[0].Eval(input...))
+        r2 := operator.Logistic.Eval(l.random[1].Eval(input...))
+        val = parent(l.parents[0]) + l.step*(r1-r2)
+    }
+    memo[l] = val
+    return val
+}
+
+// satAdd adds two sizes, saturating instead of overflowing
+func satAdd(a, b int) int {
+    if a > math.MaxInt-b {
+        return math.MaxInt
+    }
+    return a + b
+}
+
+func maxInt(nums ...int) int {
+    max := nums[0]
+    for _, n := range nums[1:] {
+        if n > max {
+            max = n
+        }
+    }
+    return max
+}
+
+// semanticsOf returns the outputs of ind on each row of ds, calculating and
+// storing them in the individual if it doesn't have them yet.
+// Calculations are accounted as evaluations of e
+func semanticsOf(ind *Individual, ds *dataset.Dataset, e Evaluator) []float64 {
+    if ind.Semantics == nil {
+        ind.Semantics = ind.Predict(ds)
+        countEvaluation(e, ind.Size(), ds.Rows())
+    }
+    return ind.Semantics
+}
+
+// randomSemantics generates a random tree and returns it along with the outputs
+// of the logistic function applied to it on each row of ds, accounted as an evaluation of e
+func randomSemantics(gen Generator, ds *dataset.Dataset, e Evaluator) (operator.Expr, []float64) {
+    code := gen.Generate().Code
+    sem := code.EvalColumns(ds.InputColumns(), ds.Rows())
+    countEvaluation(e, len(code), ds.Rows())
+    for i, out := range sem {
+        sem[i] = operator.Logistic.Eval(out)
+    }
+    return code, sem
+}
+
+// geometric defines the structure of the geometric semantic operators.
+// Their children are not subject to MAX_DEPTH, since these trees grow by design
+type geometric struct {
+    vfunc      func(in Population) (out Population)
+    name       string
+    acceptance Acceptance
+}
+
+func (v *geometric) String() string {
+    return v.name
+}
+
+func (v *geometric) Variate(in Population) Population {
+    return v.acceptance.Accept(in, v.vfunc(in))
+}
+
+// GeometricCrossoverOp returns a geometric semantic crossover variation.
+// Children are T1*R + T2*(1-R) and T2*R + T1*(1-R), R being a random tree from gen
+// wrapped by the logistic function. Only the semantics of the children on ds are
+// calculated, and their trees are kept as a lineage. The evaluations of the
+// parents without semantics and of R are accounted as the ones of e
+func GeometricCrossoverOp(gen Generator, ds *dataset.Dataset, e Evaluator, acc Acceptance) Variation {
+    cross := func(in Population) Population {
+        t1, t2 := in[0], in[1]
+        s1, s2 := semanticsOf(t1, ds, e), semanticsOf(t2, ds, e)
+        r, rsem := randomSemantics(gen, ds, e)
+        c1 := &Individual{Semantics: make([]float64, len(rsem)), lineage: crossoverLineage(t1, t2, r)}
+        c2 := &Individual{Semantics: make([]float64, len(rsem)), lineage: crossoverLineage(t2, t1, r)}
+        for i, rv := range rsem {
+            c1.Semantics[i] = s1[i]*rv + s2[i]*(1-rv)
+            c2.Semantics[i] = s2[i]*rv + s1[i]*(1-rv)
+        }
+        return Population{c1, c2}
+    }
+    return &geometric{cross, "GeometricCrossover", acc}
+}
+
+// GeometricMutationOp returns a geometric semantic mutation variation.
+// The child is T + step*(R1-R2), R1 and R2 being random trees from gen wrapped
+// by the logistic function. Only the semantics of the child on ds are
+// calculated, and its tree is kept as a lineage. The evaluations of the parent
+// without semantics and of R1 and R2 are accounted as the ones of e
+func GeometricMutationOp(gen Generator, ds *dataset.Dataset, step float64, e Evaluator, acc Acceptance) Variation {
+    mutate := func(in Population) Population {
+        t := in[0]
+        s := semanticsOf(t, ds, e)
+        r1, rsem1 := randomSemantics(gen, ds, e)
+        r2, rsem2 := randomSemantics(gen, ds, e)
+        c := &Individual{Semantics: make([]float64, len(s)), lineage: mutationLineage(t, r1, r2, step)}
+        for i := range s {
+            c.Semantics[i] = s[i] + step*(rsem1[i]-rsem2[i])
+        }
+        return Population{c}
+    }
+    return &geometric{mutate, fmt.Sprintf("GeometricMutation(%.3f)", step), acc}
+}
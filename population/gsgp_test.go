@@ -0,0 +1,55 @@
+package pop
+
+import (
+	"testing"
+
+	dataset "github.com/franciscobonand/symb-regr-gp/datasets"
+	"github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// TestGeometricEvaluationsAreCounted applies the geometric operators of a
+// generation and checks every tree evaluated on the rows is accounted
+func TestGeometricEvaluationsAreCounted(t *testing.T) {
+    rows := 10
+    ds := &dataset.Dataset{
+        Input: make([]float64, rows),
+        Output: make([]float64, rows),
+        Variables: []string{"x0"},
+    }
+    for i := 0; i < rows; i++ {
+        ds.Input[i], ds.Output[i] = float64(i), float64(i * i)
+    }
+    rng := NewRand(1)
+    gen := NewGrowGenerator(operator.CreateOpSet("x0"), 1, 3, rng)
+    counter := &EvalCounter{}
+    e := CountingEvaluator(RMSE{DS: ds}, rows, counter)
+    cross := GeometricCrossoverOp(gen, ds, e, AlwaysAccept())
+    mut := GeometricMutationOp(gen, ds, 0.1, e, AlwaysAccept())
+
+    parents := CreatePopulation(4, gen)
+    offspring, _ := ApplyGeneticOps(parents, cross, mut, 1, 1, rng)
+    offspring.Evaluate(e, 1)
+
+    // every parent is evaluated once to get its semantics, as is the random
+    // tree of each crossover pair and the two of each mutation, and the
+    // fitness of the children is calculated from their semantics
+    evals, nodes := 0, 0
+    for _, ind := range parents {
+        evals++
+        nodes += ind.Size()
+    }
+    for i, ind := range offspring {
+        mutation := ind.lineage
+        evals += 2
+        nodes += len(mutation.random[0]) + len(mutation.random[1])
+        if i % 2 == 0 {
+            evals++
+            nodes += len(mutation.parents[0].lineage.random[0])
+        }
+        evals++
+    }
+    want := EvalCount{ Fitness: int64(evals), Rows: int64(evals * rows), Nodes: int64(nodes * rows) }
+    if got := counter.Load(); got != want {
+        t.Errorf("got %+v, want %+v", got, want)
+    }
+}
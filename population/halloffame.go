@@ -0,0 +1,102 @@
+package pop
+
+import (
+    "fmt"
+    "sort"
+)
+
+// Member is an individual of the hall of fame along with the generation it was found
+type Member struct {
+    *Individual
+    Generation int
+}
+
+// HallOfFame keeps the best unique individuals found along all generations.
+// Individuals are considered the same if they have the same canonical expression
+type HallOfFame struct {
+    size      int
+    evaluator Evaluator
+    members   []Member
+    keys      map[string]bool
+}
+
+// NewHallOfFame returns an empty hall of fame that keeps up to size individuals,
+// being the best ones defined by e
+func NewHallOfFame(size int, e Evaluator) *HallOfFame {
+    return &HallOfFame{
+        size: size,
+        evaluator: e,
+        keys: map[string]bool{},
+    }
+}
+
+// key returns the canonical expression of an individual.
+// Geometric semantic trees too big to be built are identified by their address
+func key(ind *Individual) string {
+    if ind.lineage != nil && ind.lineage.size > maxFormatSize {
+        return fmt.Sprintf("%p", ind.lineage)
+    }
+    return ind.Expr().Canonical()
+}
+
+// Update adds the individuals of an evaluated population that are better than
+// the members of the hall of fame, recording gen as the generation they were found
+func (h *HallOfFame) Update(pop Population, gen int) {
+    if h.size <= 0 {
+        return
+    }
+    for _, ind := range pop {
+        if !validFitness(ind) {
+            continue
+        }
+        full := len(h.members) >= h.size
+        if full && !h.evaluator.CompareFitness(ind.Fitness, h.members[len(h.members)-1].Fitness) {
+            continue
+        }
+        k := key(ind)
+        if h.keys[k] {
+            continue
+        }
+        if full {
+            delete(h.keys, key(h.members[len(h.members)-1].Individual))
+            h.members = h.members[:len(h.members)-1]
+        }
+        h.keys[k] = true
+        h.members = append(h.members, Member{ind.Clone(), gen})
+        sort.SliceStable(h.members, func(i, j int) bool {
+            return h.evaluator.CompareFitness(h.members[i].Fitness, h.members[j].Fitness)
+        })
+    }
+}
+
+// Reevaluate calculates the fitness of the members again with e, which defines
+// the best ones from then on. Members that become invalid are removed
+func (h *HallOfFame) Reevaluate(e Evaluator) {
+    h.evaluator = e
+    members := h.members[:0]
+    for _, m := range h.members {
+        m.Fitness, m.FitnessValid = fitness(e, m.Individual)
+        if validFitness(m.Individual) {
+            members = append(members, m)
+        } else {
+            delete(h.keys, key(m.Individual))
+        }
+    }
+    h.members = members
+    sort.SliceStable(h.members, func(i, j int) bool {
+        return h.evaluator.CompareFitness(h.members[i].Fitness, h.members[j].Fitness)
+    })
+}
+
+// Members returns the members of the hall of fame, from the best to the worst
+func (h *HallOfFame) Members() []Member {
+    return h.members
+}
+
+// Best returns the best individual ever found
+func (h *HallOfFame) Best() Member {
+    if len(h.members) == 0 {
+        return Member{&Individual{}, -1}
+    }
+    return h.members[0]
+}
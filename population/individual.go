@@ -2,15 +2,23 @@ package pop
 
 import (
 	"fmt"
+	"math"
+
+	"github.com/franciscobonand/symb-regr-gp/datasets"
 	"github.com/franciscobonand/symb-regr-gp/operator"
 )
 
 
-// Individual is a member of the population. Code represents its genome
+// Individual is a member of the population. Code represents its genome.
+// Semantics, when not nil, holds the outputs of the individual on each row of
+// the training dataset. Individuals created by geometric semantic operators
+// have no Code, which is built on demand from their lineage
 type Individual struct {
 	Code         operator.Expr
 	Fitness      float64
 	FitnessValid bool
+	Semantics    []float64
+	lineage      *lineage
 	depth        int
 }
 
@@ -19,31 +27,80 @@ func Create(code operator.Expr) *Individual {
 	return &Individual{Code: code.Clone()}
 }
 
-// Clone returns a deep copy of the given individual
+// Clone returns a deep copy of the given individual.
+// Semantics and lineage are never modified once set, so they are shared
 func (ind *Individual) Clone() *Individual {
 	return &Individual{
 		Code:         ind.Code.Clone(),
 		Fitness:      ind.Fitness,
 		FitnessValid: ind.FitnessValid,
+		Semantics:    ind.Semantics,
+		lineage:      ind.lineage,
 	}
 }
 
 // String returns a textual representation of the individual
 func (ind Individual) String() string {
+	formula := ind.Format()
 	if ind.FitnessValid {
-		return fmt.Sprintf("%6.3f  %s", ind.Fitness, ind.Code.Format())
+		return fmt.Sprintf("%6.3f  %s", ind.Fitness, formula)
 	} else {
-		return fmt.Sprintf("%6s  %s", "????", ind.Code.Format())
+		return fmt.Sprintf("%6s  %s", "????", formula)
+	}
+}
+
+// Format returns the individual's expression in infix notation.
+// Geometric semantic individuals too big to be built only show their size
+func (ind *Individual) Format() string {
+	if ind.lineage != nil && ind.lineage.size > maxFormatSize {
+		return fmt.Sprintf("<geometric semantic tree with %d nodes>", ind.lineage.size)
+	}
+	return ind.Expr().Format()
+}
+
+// Expr returns the individual's genome, building it from its lineage if needed
+func (ind *Individual) Expr() operator.Expr {
+	if ind.lineage != nil {
+		return ind.lineage.materialize()
+	}
+	return ind.Code
+}
+
+// Eval evaluates the individual's genome for the given input values
+func (ind *Individual) Eval(input ...float64) float64 {
+	if ind.lineage != nil {
+		return ind.lineage.eval(input, map[*lineage]float64{})
+	}
+	return ind.Code.Eval(input...)
+}
+
+// Predict returns the outputs of the individual on every row of ds
+func (ind *Individual) Predict(ds *dataset.Dataset) []float64 {
+	if ind.lineage == nil {
+		return ind.Code.EvalColumns(ds.InputColumns(), ds.Rows())
+	}
+	out := make([]float64, ds.Rows())
+	var input []float64
+	for i := range out {
+		input = ds.Row(i, input)
+		out[i] = ind.Eval(input...)
 	}
+	return out
 }
 
 // Size returns the length of the individual's genome
 func (ind *Individual) Size() int {
+	if ind.lineage != nil {
+		return ind.lineage.size
+	}
 	return len(ind.Code)
 }
 
 // Depth returns the depth of the code tree for the individual
 func (ind *Individual) Depth() int {
+	if ind.lineage != nil {
+		return ind.lineage.depth
+	}
 	if len(ind.Code) == 0 {
 		return 0
 	}
@@ -52,3 +109,8 @@ func (ind *Individual) Depth() int {
 	}
 	return ind.depth
 }
+
+// validFitness reports whether the individual can take part in fitness based computations
+func validFitness(ind *Individual) bool {
+	return ind.FitnessValid && !math.IsNaN(ind.Fitness) && !math.IsInf(ind.Fitness, 0)
+}
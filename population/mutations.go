@@ -0,0 +1,160 @@
+package pop
+
+import (
+    "fmt"
+    "math/rand"
+    "strings"
+
+    "github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// PointMutationOp returns a mutation variation that replaces a random node with
+// another opcode of the same arity from the operations set
+func PointMutationOp(pset *operator.OpSet, rng *rand.Rand, acc Acceptance) Variation {
+    mutate := func(ind Population) Population {
+        tree := ind[0].Code.Clone()
+        pos := rng.Intn(len(tree))
+        arity := tree[pos].Arity()
+        if arity == 0 {
+            tree[pos] = pset.RandomTerminal(rng)
+        } else if op := pset.RandomPrimitive(arity, rng); op != nil {
+            tree[pos] = op
+        }
+        ind[0] = Create(tree)
+        return ind
+    }
+    return &variation{mutate, "PointMutation", acc}
+}
+
+// HoistMutationOp returns a mutation variation that replaces the whole tree by
+// one of its random subtrees
+func HoistMutationOp(rng *rand.Rand, acc Acceptance) Variation {
+    mutate := func(ind Population) Population {
+        _, subtree := ind[0].Code.RandomSubtree(rng)
+        ind[0] = Create(subtree)
+        return ind
+    }
+    return &variation{mutate, "HoistMutation", acc}
+}
+
+// ShrinkMutationOp returns a mutation variation that replaces a random subtree
+// having one or more child nodes with a random terminal
+func ShrinkMutationOp(pset *operator.OpSet, rng *rand.Rand, acc Acceptance) Variation {
+    mutate := func(ind Population) Population {
+        nodes := []int{}
+        for pos, op := range ind[0].Code {
+            if op.Arity() > 0 {
+                nodes = append(nodes, pos)
+            }
+        }
+        if len(nodes) == 0 {
+            return ind
+        }
+        pos := nodes[rng.Intn(len(nodes))]
+        newcode := ind[0].Code.Clone().ReplaceSubtree(pos, operator.Expr{pset.RandomTerminal(rng)})
+        ind[0] = Create(newcode)
+        return ind
+    }
+    return &variation{mutate, "ShrinkMutation", acc}
+}
+
+// InsertMutationOp returns a mutation variation that inserts a random primitive
+// above a random node. The node becomes one of the arguments of the new primitive
+// and the remaining arguments are random terminals
+func InsertMutationOp(pset *operator.OpSet, rng *rand.Rand, acc Acceptance) Variation {
+    mutate := func(ind Population) Population {
+        pos, subtree := ind[0].Code.RandomSubtree(rng)
+        op := pset.Primitives[rng.Intn(len(pset.Primitives))]
+        keep := rng.Intn(op.Arity())
+        newtree := operator.Expr{op}
+        for i := 0; i < op.Arity(); i++ {
+            if i == keep {
+                newtree = append(newtree, subtree...)
+            } else {
+                newtree = append(newtree, pset.RandomTerminal(rng))
+            }
+        }
+        newcode := ind[0].Code.Clone().ReplaceSubtree(pos, newtree)
+        ind[0] = Create(newcode)
+        return ind
+    }
+    return &variation{mutate, "InsertMutation", acc}
+}
+
+// PermutationMutationOp returns a mutation variation that shuffles the arguments
+// of a random commutative node. Although the result of the node is the same,
+// the tree shape changes, which affects the following crossovers
+func PermutationMutationOp(rng *rand.Rand, acc Acceptance) Variation {
+    mutate := func(ind Population) Population {
+        tree := ind[0].Code
+        nodes := []int{}
+        for pos, op := range tree {
+            if op.Arity() > 1 && operator.IsCommutative(op) {
+                nodes = append(nodes, pos)
+            }
+        }
+        if len(nodes) == 0 {
+            return ind
+        }
+        pos := nodes[rng.Intn(len(nodes))]
+        args := []operator.Expr{}
+        for _, child := range tree.Children(pos) {
+            args = append(args, tree.Subtree(child))
+        }
+        rng.Shuffle(len(args), func(i, j int) { args[i], args[j] = args[j], args[i] })
+        newtree := operator.Expr{tree[pos]}
+        for _, arg := range args {
+            newtree = append(newtree, arg...)
+        }
+        newcode := tree.Clone().ReplaceSubtree(pos, newtree)
+        ind[0] = Create(newcode)
+        return ind
+    }
+    return &variation{mutate, "PermutationMutation", acc}
+}
+
+// GaussianMutationOp returns a mutation variation that adds gaussian noise with
+// standard deviation sigma to every constant of the tree.
+// Trees without constants are left unchanged
+func GaussianMutationOp(sigma float64, rng *rand.Rand, acc Acceptance) Variation {
+    mutate := func(ind Population) Population {
+        tree := ind[0].Code.Clone()
+        changed := false
+        for pos, op := range tree {
+            if val, ok := operator.ConstantValue(op); ok {
+                tree[pos] = operator.Constant(val + rng.NormFloat64()*sigma)
+                changed = true
+            }
+        }
+        if changed {
+            ind[0] = Create(tree)
+        }
+        return ind
+    }
+    return &variation{mutate, fmt.Sprintf("GaussianMutation(%.3f)", sigma), acc}
+}
+
+// mixture defines a variation that applies one of its variations, chosen at random
+type mixture struct {
+    ops []Variation
+    cum []float64
+    rng *rand.Rand
+}
+
+// MixtureOp returns a variation that, every time it is applied, chooses one of
+// ops with probability proportional to its weight, drawn with rng
+func MixtureOp(ops []Variation, weights []float64, rng *rand.Rand) Variation {
+    return &mixture{ops, cumulative(weights), rng}
+}
+
+func (m *mixture) String() string {
+    names := make([]string, len(m.ops))
+    for i, op := range m.ops {
+        names[i] = op.String()
+    }
+    return fmt.Sprintf("Mixture(%s)", strings.Join(names, ", "))
+}
+
+func (m *mixture) Variate(in Population) Population {
+    return m.ops[pickWeighted(m.cum, m.rng)].Variate(in)
+}
@@ -23,21 +23,32 @@ func (pop Population) Len() int {
     return len(pop)
 }
 
+// Swap defines the Swap interface method for sorting a Population
+func (pop Population) Swap(i, j int) {
+    pop[i], pop[j] = pop[j], pop[i]
+}
+
+// byFitness sorts a population from the best to the worst individual according to
+// the Evaluator's CompareFitness. Individuals with invalid fitness come last
+type byFitness struct {
+    Population
+    e Evaluator
+}
+
 // Less defines the Less interface method for sorting a Population
-func (pop Population) Less(i, j int) bool {
-    if !pop[i].FitnessValid {
+func (b byFitness) Less(i, j int) bool {
+    if !validFitness(b.Population[i]) {
         return false
     }
-    if !pop[j].FitnessValid {
+    if !validFitness(b.Population[j]) {
         return true
     }
-    // should use 'CompareFitness' method so this could be generic/customizable
-    return pop[i].Fitness < pop[j].Fitness
+    return b.e.CompareFitness(b.Population[i].Fitness, b.Population[j].Fitness)
 }
 
-// Swap defines the Swap interface method for sorting a Population
-func (pop Population) Swap(i, j int) {
-    pop[i], pop[j] = pop[j], pop[i]
+// Sort sorts the population in place from the best to the worst individual according to e
+func (pop Population) Sort(e Evaluator) {
+    sort.Stable(byFitness{pop, e})
 }
 
 // Print prints out every individual from a population
@@ -56,21 +67,28 @@ func (pop Population) Clone() Population {
     return newpop
 }
 
+// Invalidate marks the fitness of every individual as outdated, so they're evaluated again
+func (pop Population) Invalidate() {
+    for _, ind := range pop {
+        ind.FitnessValid = false
+    }
+}
+
 // Best returns the individual with the best fitness
 func (pop Population) Best(e Evaluator) *Individual {
     best := &Individual{}
     for _, ind := range pop {
-        if ind.FitnessValid && (!best.FitnessValid || e.CompareFitness(ind.Fitness, best.Fitness)) {
+        if validFitness(ind) && (!best.FitnessValid || e.CompareFitness(ind.Fitness, best.Fitness)) {
             best = ind
         }
     }
     return best
 }
 
-// NBest returns the first nind best individuals
-func (pop Population) NBest(nind int) Population {
+// NBest returns the first nind best individuals according to e
+func (pop Population) NBest(nind int, e Evaluator) Population {
     clone := pop.Clone()
-    sort.Sort(clone)
+    clone.Sort(e)
     if len(clone) < nind {
         nind = len(clone)
     }
@@ -81,11 +99,12 @@ type Stats struct {
     Repeated, MaxSize, MinSize, MeanSize, BestFit, WorstFit, MeanFit float64
 }
 
-// FitnessStats returns best, worst and mean fitness of a population
-func (pop Population) GetStats() Stats {
+// GetStats returns size and fitness stats of a population.
+// Best and worst fitness are defined by e, and only valid individuals are
+// considered for the fitness stats
+func (pop Population) GetStats(e Evaluator) Stats {
     stats := Stats{}
-    var worstfit, meanfit float64
-    bestfit := math.MaxFloat64
+    var bestfit, worstfit, meanfit, nvalid float64
     var maxsize, meansize float64
     minsize := math.MaxFloat64
     set := map[string]bool{}
@@ -99,19 +118,22 @@ func (pop Population) GetStats() Stats {
             maxsize = sz
         }
         set[ind.String()] = true
-        if ind.FitnessValid {
-            meanfit += ind.Fitness
-            if ind.Fitness < bestfit {
+        if validFitness(ind) {
+            if nvalid == 0 || e.CompareFitness(ind.Fitness, bestfit) {
                 bestfit = ind.Fitness
             }
-            if ind.Fitness > worstfit {
+            if nvalid == 0 || e.CompareFitness(worstfit, ind.Fitness) {
                 worstfit = ind.Fitness
             }
+            meanfit += ind.Fitness
+            nvalid++
         }
     }
     stats.BestFit = bestfit
     stats.WorstFit = worstfit
-    stats.MeanFit = meanfit/float64(len(pop))
+    if nvalid > 0 {
+        stats.MeanFit = meanfit/nvalid
+    }
     stats.Repeated = float64(len(pop) - len(set))
     stats.MaxSize = maxsize
     stats.MinSize = minsize
@@ -0,0 +1,37 @@
+package pop
+
+import (
+    "math/rand"
+    "sync"
+)
+
+// lockedSource is a random source safe for concurrent use, as the global one of math/rand
+type lockedSource struct {
+    mu  sync.Mutex
+    src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.src.Seed(seed)
+}
+
+// NewRand returns a random number generator seeded with seed that can be shared
+// by the threads of a run. It draws the same numbers as the global generator of
+// math/rand seeded with seed, so each run has its own and reproducible sequence
+func NewRand(seed int64) *rand.Rand {
+    return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
@@ -0,0 +1,68 @@
+package pop
+
+import (
+	"math/rand"
+
+	dataset "github.com/franciscobonand/symb-regr-gp/datasets"
+	"github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// sampling is an Evaluator that calculates the fitness on a sample of the rows
+// of a dataset, which is drawn again at every generation
+type sampling struct {
+    newEval     func(ds *dataset.Dataset) Evaluator
+    ds          *dataset.Dataset
+    size        int
+    interleaved bool
+    gen         int
+    current     Evaluator
+    rows        int
+    rng         *rand.Rand
+}
+
+// RandomSamplingEvaluator returns an evaluator that calculates the fitness with
+// the evaluator newEval creates for a random sample of size rows of ds, drawn
+// again with rng at every generation (random sampling technique)
+func RandomSamplingEvaluator(newEval func(ds *dataset.Dataset) Evaluator, ds *dataset.Dataset, size int, rng *rand.Rand) *sampling {
+    e := &sampling{newEval: newEval, ds: ds, size: size, rng: rng}
+    e.draw()
+    return e
+}
+
+// InterleavedSamplingEvaluator returns an evaluator like RandomSamplingEvaluator
+// that alternates between every row of ds, on even generations, and a random
+// sample of size rows, on odd ones (interleaved sampling)
+func InterleavedSamplingEvaluator(newEval func(ds *dataset.Dataset) Evaluator, ds *dataset.Dataset, size int, rng *rand.Rand) *sampling {
+    e := &sampling{newEval: newEval, ds: ds, size: size, interleaved: true, rng: rng}
+    e.draw()
+    return e
+}
+
+// draw sets the evaluator of the rows used by the current generation
+func (e *sampling) draw() {
+    sample := e.ds
+    if !e.interleaved || e.gen%2 == 1 {
+        sample = e.ds.Sample(e.size, e.rng)
+    }
+    e.current, e.rows = e.newEval(sample), len(sample.Output)
+}
+
+// NextGeneration draws the rows of the next generation. The fitness of the
+// individuals evaluated before is no longer comparable, so they must be evaluated again
+func (e *sampling) NextGeneration() {
+    e.gen++
+    e.draw()
+}
+
+// Rows returns the number of rows used by the current generation
+func (e *sampling) Rows() int {
+    return e.rows
+}
+
+func (e *sampling) GetFitness(code operator.Expr) (float64, bool) {
+    return e.current.GetFitness(code)
+}
+
+func (e *sampling) CompareFitness(a, b float64) bool {
+    return e.current.CompareFitness(a, b)
+}
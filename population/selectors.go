@@ -2,7 +2,9 @@ package pop
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"sync"
 
 	dataset "github.com/franciscobonand/symb-regr-gp/datasets"
@@ -21,15 +23,19 @@ type tournament struct {
     threads int
     indivSelector Selector
     evaluator Evaluator
+    rng *rand.Rand
 }
 
-func TournamentSelector(elsize, tsize, t int, e Evaluator) Selector {
+// TournamentSelector returns a tournament selector whose contestants are drawn
+// with rng, which must be safe for concurrent use if t > 1
+func TournamentSelector(elsize, tsize, t int, e Evaluator, rng *rand.Rand) Selector {
     return tournament{
         elitismSize: elsize,
         tournamentSize: tsize,
         threads: t,
-        indivSelector: randomSel{},
+        indivSelector: randomSel{rng: rng},
         evaluator: e,
+        rng: rng,
     }
 }
 
@@ -40,7 +46,7 @@ func (s tournament) String() string {
 func (s tournament) Select(pop Population, num int) Population {
     chosen := Population{}
     if s.elitismSize > 0 {
-        chosen = pop.NBest(s.elitismSize)
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
     }
 
     threads := s.threads
@@ -74,54 +80,105 @@ func (s tournament) Select(pop Population, num int) Population {
 func (s tournament) tourSelection(wg *sync.WaitGroup, start, end int, pop Population, cn chan *Individual) {
     for i := start; i < end; i++ {
         group := s.indivSelector.Select(pop, s.tournamentSize)
-        best := group.Best(s.evaluator)
-        if !best.FitnessValid {
-            panic("no best individual found!")
-        }
-        cn <- best
+        cn <- bestOrRandom(group, s.evaluator, s.rng)
     }
     wg.Done()
 }
 
+// bestOrRandom returns the best individual of a tournament group according to e,
+// or a random one if none of them has a valid fitness, as when every tree overflows
+func bestOrRandom(group Population, e Evaluator, rng *rand.Rand) *Individual {
+    best := group.Best(e)
+    if !best.FitnessValid {
+        return group[rng.Intn(len(group))]
+    }
+    return best
+}
+
+// FitnessTransform defines how the fitness of an individual is mapped to its
+// share of the roulette in fitness proportionate selection
+type FitnessTransform int
+
+const (
+    // WindowTransform uses the distance to the worst fitness of the population
+    WindowTransform FitnessTransform = iota
+    // InverseTransform uses 1 / (1 + d), d being the distance to the best fitness
+    InverseTransform
+    // RankTransform uses n - r, r being the rank of the individual (0 is the best)
+    RankTransform
+)
+
+func (t FitnessTransform) String() string {
+    switch t {
+    case InverseTransform:
+        return "inverse"
+    case RankTransform:
+        return "rank"
+    default:
+        return "window"
+    }
+}
+
 // roulette defines a structure to select individuals using the roulette method
 type roulette struct {
     elitismSize int
+    transform FitnessTransform
     evaluator Evaluator
+    rng *rand.Rand
 }
 
-func RouletteSelector(elsize int, e Evaluator) Selector {
+func RouletteSelector(elsize int, t FitnessTransform, e Evaluator, rng *rand.Rand) Selector {
     return roulette{
         elitismSize: elsize,
+        transform: t,
         evaluator: e,
+        rng: rng,
     }
 }
 
 func (s roulette) String() string {
-    return "Roulette"
+    return fmt.Sprintf("Roulette(%s)", s.transform)
 }
 
-func (s roulette) Select(pop Population, num int) Population {
-    chosen := Population{}
-    fitSum := 0.0
-    percSum := 0.0
-    for _, indiv := range pop {
-        fitSum += indiv.Fitness
+// rouletteWeights returns the proportion of the roulette taken by each individual.
+// The transform is driven by e.CompareFitness, so it works both for minimization and
+// maximization. Individuals with invalid, NaN or infinite fitness get no share
+func rouletteWeights(pop Population, t FitnessTransform, e Evaluator) []float64 {
+    weights := make([]float64, len(pop))
+    valid := make([]int, 0, len(pop))
+    for i, ind := range pop {
+        if validFitness(ind) {
+            valid = append(valid, i)
+        }
     }
-    for _, indiv := range pop {
-        percSum += (1 - indiv.Fitness/fitSum)
+    if len(valid) == 0 {
+        return weights
+    }
+    sort.SliceStable(valid, func(i, j int) bool {
+        return e.CompareFitness(pop[valid[i]].Fitness, pop[valid[j]].Fitness)
+    })
+    best, worst := pop[valid[0]].Fitness, pop[valid[len(valid)-1]].Fitness
+    for r, i := range valid {
+        switch t {
+        case InverseTransform:
+            weights[i] = 1 / (1 + math.Abs(pop[i].Fitness-best))
+        case RankTransform:
+            weights[i] = float64(len(valid) - r)
+        default:
+            weights[i] = math.Abs(pop[i].Fitness - worst)
+        }
     }
+    return weights
+}
+
+func (s roulette) Select(pop Population, num int) Population {
+    chosen := Population{}
     if s.elitismSize > 0 {
-        chosen = pop.NBest(s.elitismSize)
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
     }
+    cum := cumulative(rouletteWeights(pop, s.transform, s.evaluator))
     for i := 0; i < num - s.elitismSize; i++ {
-        val := rand.Float64() * percSum
-        for idx := range pop {
-            val -= (1 - pop[idx].Fitness/fitSum)
-            if val <= 0 {
-                chosen = append(chosen, pop[idx])
-                break
-            }
-        }
+        chosen = append(chosen, pop[pickWeighted(cum, s.rng)])
     }
     return chosen
 }
@@ -129,11 +186,15 @@ func (s roulette) Select(pop Population, num int) Population {
 // randomSel defines a structure to select individuals at random
 type randomSel struct {
     elitismSize int
+    evaluator Evaluator
+    rng *rand.Rand
 }
 
-func RandomSelector(elsize int) Selector {
+func RandomSelector(elsize int, e Evaluator, rng *rand.Rand) Selector {
 	return randomSel{
         elitismSize: elsize,
+        evaluator: e,
+        rng: rng,
     }
 }
 
@@ -144,10 +205,10 @@ func (s randomSel) String() string {
 func (s randomSel) Select(pop Population, num int) Population {
 	chosen := Population{}
     if s.elitismSize > 0 {
-        chosen = pop.NBest(s.elitismSize)
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
     }
 	for i := 0; i < num - s.elitismSize; i++ {
-		chosen = append(chosen, pop[rand.Intn(len(pop))])
+		chosen = append(chosen, pop[s.rng.Intn(len(pop))])
 	}
 	return chosen
 }
@@ -157,14 +218,22 @@ type lexicase struct {
     elitismSize, threads int
     ds dataset.Dataset
     evaluator Evaluator
+    caseEval func(ds *dataset.Dataset) Evaluator
+    rng *rand.Rand
 }
 
-func LexicaseSelector(elsize, t int, e Evaluator, ds dataset.Dataset) Selector {
+// LexicaseSelector returns a lexicase selector over the cases (rows) of ds, whose
+// fitness is calculated by the evaluators caseEval returns for datasets of a single row.
+// If e is a counting evaluator, the evaluations of each case are also accounted.
+// The order of the cases is drawn with rng
+func LexicaseSelector(elsize, t int, e Evaluator, ds dataset.Dataset, caseEval func(ds *dataset.Dataset) Evaluator, rng *rand.Rand) Selector {
     return lexicase{
         elitismSize: elsize,
         threads: t,
         evaluator: e,
         ds: ds,
+        caseEval: caseEval,
+        rng: rng,
     }
 }
 
@@ -175,74 +244,398 @@ func (s lexicase) String() string {
 func (s lexicase) Select(pop Population, num int) Population {
     chosen := Population{}
     if s.elitismSize > 0 {
-        chosen = pop.NBest(s.elitismSize)
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
+    }
+    errors := s.caseErrors(pop)
+    cases := make([]int, len(s.ds.Output))
+    for i := range cases {
+        cases[i] = i
+    }
+    for i := 0; i < num - s.elitismSize; i++ {
+        chosen = append(chosen, pop[s.lexSelection(errors, cases)])
     }
+    return chosen
+}
 
+// caseErrors returns the fitness of every individual on each case.
+// The evaluation process can be done in parallel
+func (s lexicase) caseErrors(pop Population) [][]float64 {
+    evaluators := make([]Evaluator, len(s.ds.Output))
+    for c := range evaluators {
+        evaluators[c] = countAs(s.evaluator, s.caseEval(s.ds.Subset([]int{c})), 1)
+    }
+    // invalid fitness is the worst on the case
+    worst := math.Inf(1)
+    if s.evaluator.CompareFitness(worst, 0) {
+        worst = math.Inf(-1)
+    }
+    errors := make([][]float64, len(pop))
     threads := s.threads
-	chunkSize := (num - s.elitismSize) / threads
+	chunkSize := len(pop) / threads
 	if chunkSize < 1 {
 		chunkSize = 1
         threads = 1
 	}
 	start := 0
 	end := chunkSize
-    cn := make(chan *Individual, num - s.elitismSize)
 	var wg sync.WaitGroup
 	wg.Add(threads)
     for chunk := 0; chunk < threads; chunk++ {
         if chunk == threads - 1 {
-            end = (num - s.elitismSize)
+            end = len(pop)
         }
-        go s.lexSelection(&wg, start, end, &pop, cn)
+        go func(start, end int) {
+            for i := start; i < end; i++ {
+                errors[i] = make([]float64, len(evaluators))
+                for c, e := range evaluators {
+                    fit, ok := e.GetFitness(pop[i].Code)
+                    if !ok || math.IsNaN(fit) {
+                        fit = worst
+                    }
+                    errors[i][c] = fit
+                }
+            }
+            wg.Done()
+        }(start, end)
         start += chunkSize
         end += chunkSize
     }
     wg.Wait()
-    close(cn)
-    for ind := range cn {
-        chosen = append(chosen, ind)
+    return errors
+}
+
+// lexSelection returns the index of the individual chosen by going through the
+// cases in random order, keeping only the candidates with the best fitness on each case.
+// If the dataset is weighted, cases of greater weight tend to come first
+func (s lexicase) lexSelection(errors [][]float64, cases []int) int {
+    candidates := make([]int, len(errors))
+    for i := range candidates {
+        candidates[i] = i
+    }
+    if s.ds.Weights != nil {
+        weightedShuffle(cases, s.ds.Weights, s.rng)
+    } else {
+        s.rng.Shuffle(len(cases), func(i, j int) { cases[i], cases[j] = cases[j], cases[i] })
     }
+    for _, c := range cases {
+        best := errors[candidates[0]][c]
+        for _, i := range candidates[1:] {
+            if s.evaluator.CompareFitness(errors[i][c], best) {
+                best = errors[i][c]
+            }
+        }
+        // Remove all indiv with fitness worse than the best fitness for this case
+        remaining := candidates[:0]
+        for _, i := range candidates {
+            if errors[i][c] == best {
+                remaining = append(remaining, i)
+            }
+        }
+        candidates = remaining
+        // If there's only one candidate, it's chosen as parent
+        if len(candidates) == 1 {
+            return candidates[0]
+        }
+    }
+    // When there are no cases left, pick one indiv at random
+    return candidates[s.rng.Intn(len(candidates))]
+}
+
+// weightedShuffle orders the cases as drawn one by one without replacement with
+// probability proportional to their weights. Each case gets the key u^(1/w), u
+// being uniform in (0, 1), and the cases are sorted by decreasing key
+// (Efraimidis and Spirakis). Cases of zero weight come last, in random order
+func weightedShuffle(cases []int, weights []float64, rng *rand.Rand) {
+    keys := make([]float64, len(weights))
+    for _, c := range cases {
+        // log(u)/w preserves the order of u^(1/w) without underflowing
+        keys[c] = math.Inf(-1)
+        if weights[c] > 0 {
+            keys[c] = math.Log(1 - rng.Float64()) / weights[c]
+        }
+    }
+    rng.Shuffle(len(cases), func(i, j int) { cases[i], cases[j] = cases[j], cases[i] })
+    sort.SliceStable(cases, func(i, j int) bool {
+        return keys[cases[i]] > keys[cases[j]]
+    })
+}
+
+// sortedByFitness returns a copy of the population sorted from the best to the worst individual according to e
+func sortedByFitness(pop Population, e Evaluator) Population {
+    sorted := append(Population{}, pop...)
+    sorted.Sort(e)
+    return sorted
+}
+
+// cumulative returns the cumulative sum of the given weights
+func cumulative(weights []float64) []float64 {
+    cum := make([]float64, len(weights))
+    acc := 0.0
+    for i, w := range weights {
+        acc += w
+        cum[i] = acc
+    }
+    return cum
+}
+
+// pickWeighted picks an index from a cumulative weights table using binary search.
+// If all weights are zero the index is chosen uniformly. The index is drawn with rng
+func pickWeighted(cum []float64, rng *rand.Rand) int {
+    total := cum[len(cum)-1]
+    if total <= 0 {
+        return rng.Intn(len(cum))
+    }
+    val := rng.Float64() * total
+    idx := sort.Search(len(cum), func(i int) bool { return cum[i] > val })
+    if idx >= len(cum) {
+        idx = len(cum) - 1
+    }
+    return idx
+}
 
+// rank defines a structure to select individuals based on their rank when
+// sorted by fitness, instead of the fitness value itself
+type rank struct {
+    elitismSize int
+    pressure    float64
+    base        float64
+    exponential bool
+    evaluator   Evaluator
+    rng         *rand.Rand
+}
+
+// LinearRankSelector returns a selector where the probability of an individual
+// being chosen decreases linearly with its rank. pressure must be in [1, 2],
+// being 1 equivalent to random selection and 2 the highest selective pressure
+func LinearRankSelector(elsize int, pressure float64, e Evaluator, rng *rand.Rand) Selector {
+    return rank{
+        elitismSize: elsize,
+        pressure: pressure,
+        evaluator: e,
+        rng: rng,
+    }
+}
+
+// ExponentialRankSelector returns a selector where the individual at rank r
+// (0 being the best) is chosen with probability proportional to base^r.
+// base must be in (0, 1), and smaller values mean higher selective pressure
+func ExponentialRankSelector(elsize int, base float64, e Evaluator, rng *rand.Rand) Selector {
+    return rank{
+        elitismSize: elsize,
+        base: base,
+        exponential: true,
+        evaluator: e,
+        rng: rng,
+    }
+}
+
+func (s rank) String() string {
+    if s.exponential {
+        return fmt.Sprintf("ExponentialRank(%.2f)", s.base)
+    }
+    return fmt.Sprintf("LinearRank(%.2f)", s.pressure)
+}
+
+// weights returns the selection weight of each individual of a sorted population.
+// Only valid individuals, which come first, are ranked, the others having weight 0,
+// so individuals are chosen uniformly if none is valid
+func (s rank) weights(sorted Population) []float64 {
+    weights := make([]float64, len(sorted))
+    n := 0
+    for n < len(sorted) && validFitness(sorted[n]) {
+        n++
+    }
+    for r := range weights[:n] {
+        if s.exponential {
+            weights[r] = math.Pow(s.base, float64(r))
+        } else if n > 1 {
+            // r = 0 is the best individual, which receives weight 'pressure'
+            weights[r] = s.pressure - 2*(s.pressure-1)*float64(r)/float64(n-1)
+        } else {
+            weights[r] = 1
+        }
+    }
+    return weights
+}
+
+func (s rank) Select(pop Population, num int) Population {
+    chosen := Population{}
+    if s.elitismSize > 0 {
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
+    }
+    sorted := sortedByFitness(pop, s.evaluator)
+    cum := cumulative(s.weights(sorted))
+    for i := 0; i < num - s.elitismSize; i++ {
+        chosen = append(chosen, sorted[pickWeighted(cum, s.rng)])
+    }
     return chosen
 }
 
-func (s lexicase) lexSelection(wg *sync.WaitGroup, start, end int, pop *Population, cn chan *Individual) {
-    for i := start; i < end; i++ {
-        cases := s.ds.Copy()
-        tempCandidates := pop.Clone()
-        for {
-            // When there are no cases left, pick one indiv at random
-            if len(cases.Output) == 0 {
-                cn <- tempCandidates[rand.Intn(len(tempCandidates))]
-                break
-            }
-            cIdx := rand.Intn(len(cases.Output))
-            cin := [][]float64{ cases.Input[cIdx] }
-            cout := []float64{ cases.Output[cIdx] }
-            rmse := RMSE{
-                &dataset.Dataset{
-                    Input: cin,
-                    Output: cout,
-                },
-            }
-            auxcand, _ := tempCandidates.Evaluate(rmse, 1)
-            best := tempCandidates.Best(s.evaluator)
-            tempCandidates = Population{}
-            // Remove all indiv with fitness worse than the best fitness for this case
-            for _, ind := range auxcand {
-                if ind.Fitness == best.Fitness {
-                    tempCandidates = append(tempCandidates, ind)
-                }
-            }
-            // If there's only one candidate, it's chosen as parent
-            if len(tempCandidates) == 1 {
-                cn <- tempCandidates[0]
-                break
-            }
-            // Remove used case
-            cases.Input = append(cases.Input[:cIdx], cases.Input[cIdx+1:]...)
-            cases.Output = append(cases.Output[:cIdx], cases.Output[cIdx+1:]...)
+// sus defines a structure to select individuals using stochastic universal sampling
+type sus struct {
+    elitismSize int
+    transform FitnessTransform
+    evaluator Evaluator
+    rng *rand.Rand
+}
+
+// SUSSelector returns a fitness proportionate selector which uses a single
+// random value to place num equally spaced pointers over the roulette,
+// reducing the variance of the number of copies of each individual
+func SUSSelector(elsize int, t FitnessTransform, e Evaluator, rng *rand.Rand) Selector {
+    return sus{
+        elitismSize: elsize,
+        transform: t,
+        evaluator: e,
+        rng: rng,
+    }
+}
+
+func (s sus) String() string {
+    return fmt.Sprintf("StochasticUniversalSampling(%s)", s.transform)
+}
+
+func (s sus) Select(pop Population, num int) Population {
+    chosen := Population{}
+    if s.elitismSize > 0 {
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
+    }
+    n := num - s.elitismSize
+    if n <= 0 {
+        return chosen
+    }
+    cum := cumulative(rouletteWeights(pop, s.transform, s.evaluator))
+    total := cum[len(cum)-1]
+    if total <= 0 {
+        return append(chosen, RandomSelector(0, s.evaluator, s.rng).Select(pop, n)...)
+    }
+    step := total / float64(n)
+    ptr := s.rng.Float64() * step
+    idx := 0
+    for i := 0; i < n; i++ {
+        for idx < len(cum)-1 && cum[idx] < ptr {
+            idx++
         }
+        chosen = append(chosen, pop[idx])
+        ptr += step
     }
-    wg.Done()
+    // pointers are sorted, so the chosen individuals are shuffled to avoid
+    // always mating neighbours of the parent population
+    s.rng.Shuffle(n, func(i, j int) {
+        chosen[s.elitismSize+i], chosen[s.elitismSize+j] = chosen[s.elitismSize+j], chosen[s.elitismSize+i]
+    })
+    return chosen
+}
+
+// boltzmann defines a structure to select individuals using Boltzmann selection.
+// The temperature decreases at every call to Select, so the selective pressure
+// increases along the generations
+type boltzmann struct {
+    elitismSize int
+    temperature float64
+    cooling     float64
+    evaluator   Evaluator
+    rng         *rand.Rand
+}
+
+// BoltzmannSelector returns a selector where the probability of an individual
+// being chosen is proportional to exp(-d/T), d being the distance between its
+// fitness and the best fitness. T starts at temp and is multiplied by cooling
+// after every selection
+func BoltzmannSelector(elsize int, temp, cooling float64, e Evaluator, rng *rand.Rand) Selector {
+    return &boltzmann{
+        elitismSize: elsize,
+        temperature: temp,
+        cooling: cooling,
+        evaluator: e,
+        rng: rng,
+    }
+}
+
+func (s *boltzmann) String() string {
+    return fmt.Sprintf("Boltzmann(%.3f)", s.temperature)
+}
+
+func (s *boltzmann) Select(pop Population, num int) Population {
+    chosen := Population{}
+    if s.elitismSize > 0 {
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
+    }
+    best := pop.Best(s.evaluator)
+    weights := make([]float64, len(pop))
+    for i, ind := range pop {
+        if validFitness(ind) && best.FitnessValid {
+            weights[i] = math.Exp(-math.Abs(ind.Fitness-best.Fitness) / s.temperature)
+        }
+    }
+    cum := cumulative(weights)
+    for i := 0; i < num - s.elitismSize; i++ {
+        chosen = append(chosen, pop[pickWeighted(cum, s.rng)])
+    }
+    s.temperature *= s.cooling
+    if s.temperature < minTemperature {
+        s.temperature = minTemperature
+    }
+    return chosen
+}
+
+// minTemperature avoids the Boltzmann temperature reaching zero
+const minTemperature = 1e-6
+
+// doubleTournament defines a structure to select individuals using a fitness
+// tournament whose contestants are the winners of size (parsimony) tournaments
+type doubleTournament struct {
+    elitismSize    int
+    tournamentSize int
+    parsimonySize  float64
+    evaluator      Evaluator
+    rng            *rand.Rand
+}
+
+// DoubleTournamentSelector returns a size-aware tournament selector.
+// Each contestant of the fitness tournament of size tsize is the winner of a
+// size tournament between two random individuals, where the smaller one wins
+// with probability psize/2. psize must be in [1, 2], 1 meaning no size pressure
+func DoubleTournamentSelector(elsize, tsize int, psize float64, e Evaluator, rng *rand.Rand) Selector {
+    return doubleTournament{
+        elitismSize: elsize,
+        tournamentSize: tsize,
+        parsimonySize: psize,
+        evaluator: e,
+        rng: rng,
+    }
+}
+
+func (s doubleTournament) String() string {
+    return fmt.Sprintf("DoubleTournament(%d, %.2f)", s.tournamentSize, s.parsimonySize)
+}
+
+func (s doubleTournament) Select(pop Population, num int) Population {
+    chosen := Population{}
+    if s.elitismSize > 0 {
+        chosen = pop.NBest(s.elitismSize, s.evaluator)
+    }
+    for i := 0; i < num - s.elitismSize; i++ {
+        group := make(Population, s.tournamentSize)
+        for j := range group {
+            group[j] = s.sizeTournament(pop)
+        }
+        chosen = append(chosen, bestOrRandom(group, s.evaluator, s.rng))
+    }
+    return chosen
+}
+
+// sizeTournament returns the smaller of two random individuals with probability parsimonySize/2
+func (s doubleTournament) sizeTournament(pop Population) *Individual {
+    a, b := pop[s.rng.Intn(len(pop))], pop[s.rng.Intn(len(pop))]
+    if a.Size() == b.Size() {
+        return a
+    }
+    if b.Size() < a.Size() {
+        a, b = b, a
+    }
+    if s.rng.Float64() < s.parsimonySize/2 {
+        return a
+    }
+    return b
 }
@@ -0,0 +1,38 @@
+package pop
+
+import (
+	"math"
+	"testing"
+
+	"github.com/franciscobonand/symb-regr-gp/operator"
+)
+
+// TestTournamentsWithoutValidFitness checks tournaments whose contestants all
+// have invalid fitness choose one of them instead of failing
+func TestTournamentsWithoutValidFitness(t *testing.T) {
+    p := Population{}
+    for i := 0; i < 6; i++ {
+        ind := Create(operator.Expr{operator.Variable("x0", 0)})
+        ind.Fitness, ind.FitnessValid = math.NaN(), i % 2 == 0
+        p = append(p, ind)
+    }
+    members := map[*Individual]bool{}
+    for _, ind := range p {
+        members[ind] = true
+    }
+    selectors := []Selector{
+        TournamentSelector(0, 3, 2, RMSE{}, NewRand(1)),
+        DoubleTournamentSelector(0, 3, 1.4, RMSE{}, NewRand(1)),
+    }
+    for _, s := range selectors {
+        chosen := s.Select(p, 10)
+        if len(chosen) != 10 {
+            t.Fatalf("%s: got %d individuals, want 10", s, len(chosen))
+        }
+        for _, ind := range chosen {
+            if !members[ind] {
+                t.Errorf("%s: chose an individual out of the population", s)
+            }
+        }
+    }
+}
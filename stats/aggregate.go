@@ -0,0 +1,210 @@
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Summary holds the final results of a run
+type Summary struct {
+    Run      int64   `json:"run"`
+    Seed     int64   `json:"seed"`
+    TrainFit float64 `json:"trainfit"`
+    ValFit   float64 `json:"valfit"`
+    TestFit  float64 `json:"testfit"`
+    Size     int     `json:"size"`
+    Best     string  `json:"best"`
+}
+
+// SummaryHeader is the CSV header of the run summaries
+const SummaryHeader = "run,seed,trainfit,valfit,testfit,size,best\n"
+
+// WriteSummaries writes the final results of the runs as CSV lines
+func WriteSummaries(w io.Writer, summaries []Summary) error {
+    cw := csv.NewWriter(w)
+    for _, s := range summaries {
+        err := cw.Write([]string{
+            strconv.FormatInt(s.Run, 10),
+            strconv.FormatInt(s.Seed, 10),
+            strconv.FormatFloat(s.TrainFit, 'f', 6, 64),
+            strconv.FormatFloat(s.ValFit, 'f', 6, 64),
+            strconv.FormatFloat(s.TestFit, 'f', 6, 64),
+            strconv.Itoa(s.Size),
+            s.Best,
+        })
+        if err != nil {
+            return err
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// Distribution describes a sample of values
+type Distribution struct {
+    Mean, Std, Median, Q1, Q3 float64
+}
+
+// Describe returns the distribution of the values, ignoring NaNs.
+// Std is the sample standard deviation, and quartiles are linearly interpolated
+func Describe(values []float64) Distribution {
+    vals := []float64{}
+    for _, v := range values {
+        if !math.IsNaN(v) {
+            vals = append(vals, v)
+        }
+    }
+    if len(vals) == 0 {
+        nan := math.NaN()
+        return Distribution{nan, nan, nan, nan, nan}
+    }
+    sort.Float64s(vals)
+    var sum float64
+    for _, v := range vals {
+        sum += v
+    }
+    mean := sum / float64(len(vals))
+    var sq float64
+    for _, v := range vals {
+        sq += (v - mean) * (v - mean)
+    }
+    std := 0.0
+    if len(vals) > 1 {
+        std = math.Sqrt(sq / float64(len(vals) - 1))
+    }
+    return Distribution{
+        Mean: mean,
+        Std: std,
+        Median: quantile(vals, 0.5),
+        Q1: quantile(vals, 0.25),
+        Q3: quantile(vals, 0.75),
+    }
+}
+
+// MarshalJSON encodes the distribution as an object with the keys of DistributionHeader,
+// NaN values, as the ones of a sample without valid values, being null
+func (d Distribution) MarshalJSON() ([]byte, error) {
+    fields := make([]string, len(distributionStats))
+    for i, v := range d.Values() {
+        num := "null"
+        if !math.IsNaN(v) && !math.IsInf(v, 0) {
+            num = strconv.FormatFloat(v, 'g', -1, 64)
+        }
+        fields[i] = strconv.Quote(distributionStats[i]) + ":" + num
+    }
+    return []byte("{" + strings.Join(fields, ",") + "}"), nil
+}
+
+// Values returns the values of the distribution, in the order of DistributionHeader
+func (d Distribution) Values() []float64 {
+    return []float64{d.Mean, d.Std, d.Median, d.Q1, d.Q3}
+}
+
+// DistributionHeader returns the column names of the distribution of the named value
+func DistributionHeader(name string) []string {
+    header := make([]string, len(distributionStats))
+    for i, s := range distributionStats {
+        header[i] = name + "_" + s
+    }
+    return header
+}
+
+// quantile returns the q-th quantile of sorted values
+func quantile(sorted []float64, q float64) float64 {
+    pos := q * float64(len(sorted) - 1)
+    lo := int(math.Floor(pos))
+    hi := int(math.Ceil(pos))
+    return sorted[lo] + (sorted[hi] - sorted[lo]) * (pos - float64(lo))
+}
+
+// distributionStats are the suffixes of the aggregated columns of each stat
+var distributionStats = []string{"mean", "std", "median", "q1", "q3"}
+
+// numericColumns returns the indices of the numeric fields of a stats row
+// that are aggregated across runs, i.e. every one but run, seed and gen
+func numericColumns() []int {
+    t := reflect.TypeOf(Row{})
+    idx := []int{}
+    for i := 0; i < t.NumField(); i++ {
+        switch t.Field(i).Tag.Get("json") {
+        case "run", "seed", "gen":
+            continue
+        }
+        switch t.Field(i).Type.Kind() {
+        case reflect.Float64, reflect.Int, reflect.Int64:
+            idx = append(idx, i)
+        }
+    }
+    return idx
+}
+
+// AggregateHeader returns the column names of the aggregated stats
+func AggregateHeader() []string {
+    t := reflect.TypeOf(Row{})
+    header := []string{"gen"}
+    for _, i := range numericColumns() {
+        header = append(header, DistributionHeader(t.Field(i).Tag.Get("json"))...)
+    }
+    return append(header, "bestfit_best")
+}
+
+// WriteAggregate writes the aggregated stats as CSV lines, preceded by AggregateHeader
+func WriteAggregate(w io.Writer, data [][]float64) error {
+    cw := csv.NewWriter(w)
+    if err := cw.Write(AggregateHeader()); err != nil {
+        return err
+    }
+    for _, line := range data {
+        cells := make([]string, len(line))
+        for i, v := range line {
+            cells[i] = strconv.FormatFloat(v, 'f', 6, 64)
+        }
+        if err := cw.Write(cells); err != nil {
+            return err
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// Aggregate returns, for each generation, the distribution across runs of every
+// numeric stat, followed by the best fitness found by any run, with better
+// telling whether a fitness is better than another. runs[r] has the rows of
+// every generation of run r, and all runs must have the same number of generations.
+// Values are in the order of AggregateHeader
+func Aggregate(runs [][]Row, better func(a, b float64) bool) [][]float64 {
+    if len(runs) == 0 {
+        return nil
+    }
+    cols := numericColumns()
+    data := make([][]float64, len(runs[0]))
+    for g := range data {
+        line := []float64{float64(g)}
+        vals := make([]float64, len(runs))
+        for _, c := range cols {
+            for r, rows := range runs {
+                f := reflect.ValueOf(rows[g]).Field(c)
+                if f.Kind() == reflect.Float64 {
+                    vals[r] = f.Float()
+                } else {
+                    vals[r] = float64(f.Int())
+                }
+            }
+            line = append(line, Describe(vals).Values()...)
+        }
+        best := math.NaN()
+        for _, rows := range runs {
+            fit := rows[g].BestFit
+            if math.IsNaN(best) || better(fit, best) {
+                best = fit
+            }
+        }
+        data[g] = append(line, best)
+    }
+    return data
+}
@@ -0,0 +1,185 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// ranks returns the rank of every value of the concatenation of the samples,
+// ties getting their average rank, along with the tie correction sum(t^3 - t)
+// over every group of t tied values
+func ranks(samples ...[]float64) ([][]float64, float64) {
+    type item struct {
+        v    float64
+        s, i int
+    }
+    items := []item{}
+    for s, sample := range samples {
+        for i, v := range sample {
+            items = append(items, item{v, s, i})
+        }
+    }
+    sort.Slice(items, func(a, b int) bool { return items[a].v < items[b].v })
+
+    out := make([][]float64, len(samples))
+    for s, sample := range samples {
+        out[s] = make([]float64, len(sample))
+    }
+    var ties float64
+    for lo := 0; lo < len(items); {
+        hi := lo + 1
+        for hi < len(items) && items[hi].v == items[lo].v {
+            hi++
+        }
+        // ranks start at 1, so the average of lo+1..hi
+        rank := float64(lo + hi + 1) / 2.0
+        for _, it := range items[lo:hi] {
+            out[it.s][it.i] = rank
+        }
+        t := float64(hi - lo)
+        ties += t*t*t - t
+        lo = hi
+    }
+    return out, ties
+}
+
+// MannWhitney returns the U statistic of sample a and the two-sided p-value of the
+// Wilcoxon rank-sum / Mann-Whitney U test, using the normal approximation with
+// tie and continuity corrections
+func MannWhitney(a, b []float64) (float64, float64) {
+    r, ties := ranks(a, b)
+    n1, n2 := float64(len(a)), float64(len(b))
+    var r1 float64
+    for _, v := range r[0] {
+        r1 += v
+    }
+    u := r1 - n1*(n1+1)/2
+    n := n1 + n2
+    mu := n1 * n2 / 2
+    sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+    if sigma == 0 || math.IsNaN(sigma) {
+        return u, 1.0
+    }
+    z := math.Max(math.Abs(u - mu) - 0.5, 0) / sigma
+    return u, math.Erfc(z / math.Sqrt2)
+}
+
+// KruskalWallis returns the H statistic, corrected for ties, and the p-value of the
+// Kruskal-Wallis test of the samples, using the chi-squared approximation
+func KruskalWallis(samples ...[]float64) (float64, float64) {
+    r, ties := ranks(samples...)
+    var n, sum float64
+    for _, sr := range r {
+        var rs float64
+        for _, v := range sr {
+            rs += v
+        }
+        if len(sr) > 0 {
+            sum += rs * rs / float64(len(sr))
+        }
+        n += float64(len(sr))
+    }
+    h := 12/(n*(n+1))*sum - 3*(n+1)
+    if c := 1 - ties/(n*n*n-n); c > 0 {
+        h /= c
+    }
+    df := float64(len(samples) - 1)
+    if df <= 0 || math.IsNaN(h) {
+        return h, 1.0
+    }
+    return h, gammaQ(df/2, h/2)
+}
+
+// A12 returns the Vargha-Delaney effect size, the probability of a value of a being
+// greater than a value of b, counting ties as half
+func A12(a, b []float64) float64 {
+    var greater, equal float64
+    for _, x := range a {
+        for _, y := range b {
+            if x > y {
+                greater++
+            } else if x == y {
+                equal++
+            }
+        }
+    }
+    return (greater + equal/2) / float64(len(a)*len(b))
+}
+
+// EffectMagnitude returns the magnitude of a Vargha-Delaney A12 effect size
+// ('negligible', 'small', 'medium' or 'large')
+func EffectMagnitude(a12 float64) string {
+    d := math.Abs(a12 - 0.5)
+    switch {
+    case d < 0.06:
+        return "negligible"
+    case d < 0.14:
+        return "small"
+    case d < 0.21:
+        return "medium"
+    }
+    return "large"
+}
+
+// Holm returns the p-values adjusted by the Holm-Bonferroni method for multiple comparisons
+func Holm(p []float64) []float64 {
+    idx := make([]int, len(p))
+    for i := range idx {
+        idx[i] = i
+    }
+    sort.Slice(idx, func(a, b int) bool { return p[idx[a]] < p[idx[b]] })
+    adj := make([]float64, len(p))
+    var max float64
+    for k, i := range idx {
+        v := math.Min(float64(len(p) - k) * p[i], 1.0)
+        // adjusted p-values can't be smaller than the ones of smaller raw p-values
+        max = math.Max(max, v)
+        adj[i] = max
+    }
+    return adj
+}
+
+// gammaQ returns the regularized upper incomplete gamma function Q(a, x)
+func gammaQ(a, x float64) float64 {
+    if x <= 0 {
+        return 1.0
+    }
+    lg, _ := math.Lgamma(a)
+    if x < a+1 {
+        // series expansion of P(a, x)
+        sum, term := 1/a, 1/a
+        for n := 1.0; n < 500; n++ {
+            term *= x / (a + n)
+            sum += term
+            if math.Abs(term) < math.Abs(sum)*1e-15 {
+                break
+            }
+        }
+        return 1 - sum*math.Exp(-x+a*math.Log(x)-lg)
+    }
+    // continued fraction of Q(a, x), by the modified Lentz's method
+    const tiny = 1e-300
+    b := x + 1 - a
+    c := 1 / tiny
+    d := 1 / b
+    h := d
+    for i := 1.0; i < 500; i++ {
+        an := -i * (i - a)
+        b += 2
+        d = an*d + b
+        if math.Abs(d) < tiny {
+            d = tiny
+        }
+        c = b + an/c
+        if math.Abs(c) < tiny {
+            c = tiny
+        }
+        d = 1 / d
+        delta := d * c
+        h *= delta
+        if math.Abs(delta-1) < 1e-15 {
+            break
+        }
+    }
+    return math.Exp(-x+a*math.Log(x)-lg) * h
+}
@@ -0,0 +1,18 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// CreateFile creates or truncates the file of the given path, creating its
+// missing parent directories. Files are readable by everyone but only
+// writable by their owner (0644)
+func CreateFile(path string) (*os.File, error) {
+    if dir := filepath.Dir(path); dir != "." {
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            return nil, err
+        }
+    }
+    return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
@@ -0,0 +1,243 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"text/tabwriter"
+
+	pop "github.com/franciscobonand/symb-regr-gp/population"
+)
+
+// Row holds the stats of a generation of a run.
+// The json tag of each field is also used as its column name by the other reporters
+type Row struct {
+    Run           int64   `json:"run"`
+    Seed          int64   `json:"seed"`
+    Gen           int     `json:"gen"`
+    Evals         int64   `json:"evals"`
+    RowEvals      int64   `json:"rowevals"`
+    NodeEvals     int64   `json:"nodeevals"`
+    Repeated      float64 `json:"repeated"`
+    BestFit       float64 `json:"bestfit"`
+    WorstFit      float64 `json:"worstfit"`
+    MeanFit       float64 `json:"meanfit"`
+    MaxSize       float64 `json:"maxsize"`
+    MinSize       float64 `json:"minsize"`
+    MeanSize      float64 `json:"meansize"`
+    BetterCxChild float64 `json:"betterCxChild"`
+    WorseCxChild  float64 `json:"worseCxChild"`
+    Best          string  `json:"best"`
+}
+
+// NewRow returns the stats row of a generation of a run
+func NewRow(run, seed int64, gen int, evals pop.EvalCount, bCxChild, wCxChild float64, p pop.Population, e pop.Evaluator) Row {
+    s := p.GetStats(e)
+    return Row{
+        Run: run,
+        Seed: seed,
+        Gen: gen,
+        Evals: evals.Fitness,
+        RowEvals: evals.Rows,
+        NodeEvals: evals.Nodes,
+        Repeated: s.Repeated,
+        BestFit: s.BestFit,
+        WorstFit: s.WorstFit,
+        MeanFit: s.MeanFit,
+        MaxSize: s.MaxSize,
+        MinSize: s.MinSize,
+        MeanSize: s.MeanSize,
+        BetterCxChild: bCxChild,
+        WorseCxChild: wCxChild,
+        Best: p.Best(e).Format(),
+    }
+}
+
+// columns returns the column names of a stats row
+func columns() []string {
+    t := reflect.TypeOf(Row{})
+    cols := make([]string, t.NumField())
+    for i := range cols {
+        cols[i] = t.Field(i).Tag.Get("json")
+    }
+    return cols
+}
+
+// values returns the textual values of every column of the row
+func (r Row) values() []string {
+    v := reflect.ValueOf(r)
+    vals := make([]string, v.NumField())
+    for i := range vals {
+        f := v.Field(i)
+        switch f.Kind() {
+        case reflect.Float64:
+            vals[i] = strconv.FormatFloat(f.Float(), 'f', 3, 64)
+        case reflect.Int, reflect.Int64:
+            vals[i] = strconv.FormatInt(f.Int(), 10)
+        default:
+            vals[i] = fmt.Sprint(f.Interface())
+        }
+    }
+    return vals
+}
+
+// Reporter is an interface for writing the stats of each generation
+type Reporter interface {
+    Report(r Row) error
+    // Flush writes any buffered data
+    Flush() error
+}
+
+// NewReporter returns the reporter of the given format ('csv', 'json' or 'table')
+func NewReporter(format string, w io.Writer) (Reporter, error) {
+    switch format {
+    case "csv":
+        return NewCSVReporter(w), nil
+    case "json":
+        return NewJSONReporter(w), nil
+    case "table":
+        return NewTableReporter(w), nil
+    }
+    return nil, fmt.Errorf("unknown report format '%s'", format)
+}
+
+// csvReporter writes the stats as comma separated values, preceded by a header
+type csvReporter struct {
+    w      *csv.Writer
+    header bool
+}
+
+// NewCSVReporter returns a reporter that writes the stats in csv format
+func NewCSVReporter(w io.Writer) Reporter {
+    return &csvReporter{w: csv.NewWriter(w)}
+}
+
+func (c *csvReporter) Report(r Row) error {
+    if !c.header {
+        c.header = true
+        if err := c.w.Write(columns()); err != nil {
+            return err
+        }
+    }
+    if err := c.w.Write(r.values()); err != nil {
+        return err
+    }
+    c.w.Flush()
+    return c.w.Error()
+}
+
+func (c *csvReporter) Flush() error {
+    c.w.Flush()
+    return c.w.Error()
+}
+
+// jsonReporter writes the stats as JSON lines, one object per generation
+type jsonReporter struct {
+    enc *json.Encoder
+}
+
+// NewJSONReporter returns a reporter that writes the stats in JSON lines format
+func NewJSONReporter(w io.Writer) Reporter {
+    return jsonReporter{json.NewEncoder(w)}
+}
+
+func (j jsonReporter) Report(r Row) error {
+    return j.enc.Encode(r)
+}
+
+func (j jsonReporter) Flush() error {
+    return nil
+}
+
+// tableReporter writes the stats as a human readable table with aligned columns
+type tableReporter struct {
+    w      *tabwriter.Writer
+    header bool
+}
+
+// NewTableReporter returns a reporter that writes the stats as an aligned table
+func NewTableReporter(w io.Writer) Reporter {
+    return &tableReporter{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+}
+
+func (t *tableReporter) Report(r Row) error {
+    if !t.header {
+        t.header = true
+        if err := t.writeLine(columns()); err != nil {
+            return err
+        }
+    }
+    return t.writeLine(r.values())
+}
+
+func (t *tableReporter) writeLine(cells []string) error {
+    for _, cell := range cells {
+        if _, err := fmt.Fprint(t.w, cell, "\t"); err != nil {
+            return err
+        }
+    }
+    _, err := fmt.Fprintln(t.w)
+    return err
+}
+
+func (t *tableReporter) Flush() error {
+    return t.w.Flush()
+}
+
+// asyncMsg is either a row to be reported or a flush request
+type asyncMsg struct {
+    row   Row
+    flush chan error
+}
+
+// asyncReporter forwards rows to another reporter from a single goroutine.
+// Rows are immutable snapshots taken when the generation ends, so they're safe
+// to be written while the next generations modify the population, and the
+// channel guarantees they're written in the order they were reported
+type asyncReporter struct {
+    msgs chan asyncMsg
+    err  error
+}
+
+// NewAsyncReporter returns a reporter that writes the rows with r in a separate goroutine.
+// Report only fails if a previous row failed to be written
+func NewAsyncReporter(r Reporter, buffer int) Reporter {
+    a := &asyncReporter{msgs: make(chan asyncMsg, buffer)}
+    go a.run(r)
+    return a
+}
+
+func (a *asyncReporter) run(r Reporter) {
+    var err error
+    for msg := range a.msgs {
+        if msg.flush != nil {
+            if ferr := r.Flush(); err == nil {
+                err = ferr
+            }
+            msg.flush <- err
+            continue
+        }
+        if err == nil {
+            err = r.Report(msg.row)
+        }
+    }
+}
+
+func (a *asyncReporter) Report(r Row) error {
+    if a.err != nil {
+        return a.err
+    }
+    a.msgs <- asyncMsg{row: r}
+    return nil
+}
+
+// Flush waits for every reported row to be written and flushes the underlying reporter
+func (a *asyncReporter) Flush() error {
+    done := make(chan error)
+    a.msgs <- asyncMsg{flush: done}
+    a.err = <-done
+    return a.err
+}
@@ -1,45 +1,58 @@
 package stats
 
 import (
+	"encoding/csv"
 	"fmt"
-	"sync"
+	"io"
+	"strconv"
 
 	pop "github.com/franciscobonand/symb-regr-gp/population"
 )
 
-func PrintRunStats(wg *sync.WaitGroup, gen, evals , bCxChild, wCxChild float64, p pop.Population, e pop.Evaluator) {
-    s := p.GetStats()
-    fmt.Printf("%.1f,%.1f,%.1f,%.3f,%.3f,%.3f,%.1f,%.1f,%.1f,%.1f,%.1f\n",
-        gen,
-        evals,
-        s.Repeated,
-        s.BestFit,
-        s.WorstFit,
-        s.MeanFit,
-        s.MaxSize,
-        s.MinSize,
-        s.MeanSize,
-        bCxChild,
-        wCxChild,
-    )
-    wg.Done()
+// DiversityHeader returns the CSV header of the diversity stats, with a frequency column for each opcode name
+func DiversityHeader(names []string) string {
+    header := "run,gen,distinctsubtrees,editdistance,semvariance,fitentropy"
+    for _, name := range names {
+        header += ",freq_" + name
+    }
+    return header + "\n"
 }
 
-func GetRunStats(gen, evals , bCxChild, wCxChild float64, p pop.Population, e pop.Evaluator) []float64 {
-    s := p.GetStats()
-    data := []float64{
+// DiversityLine returns the CSV line of the diversity stats of a generation
+func DiversityLine(run int64, gen float64, d pop.Diversity, names []string) string {
+    line := fmt.Sprintf("%d,%.1f,%.1f,%.3f,%.3f,%.3f",
+        run,
         gen,
-        evals,
-        s.Repeated,
-        s.BestFit,
-        s.WorstFit,
-        s.MeanFit,
-        s.MaxSize,
-        s.MinSize,
-        s.MeanSize,
-        bCxChild,
-        wCxChild,
+        d.DistinctSubtrees,
+        d.EditDistance,
+        d.SemanticVariance,
+        d.FitnessEntropy,
+    )
+    for _, name := range names {
+        line += fmt.Sprintf(",%.4f", d.Frequencies[name])
     }
-    return data
+    return line + "\n"
 }
 
+// HallOfFameHeader is the CSV header of the hall of fame
+const HallOfFameHeader = "run,rank,gen,fitness,size,expression\n"
+
+// WriteHallOfFame writes the members of the hall of fame of a run as CSV lines
+func WriteHallOfFame(w io.Writer, run int64, members []pop.Member) error {
+    cw := csv.NewWriter(w)
+    for i, m := range members {
+        err := cw.Write([]string{
+            strconv.FormatInt(run, 10),
+            strconv.Itoa(i),
+            strconv.Itoa(m.Generation),
+            strconv.FormatFloat(m.Fitness, 'f', 6, 64),
+            strconv.Itoa(m.Size()),
+            m.Format(),
+        })
+        if err != nil {
+            return err
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+    "encoding/csv"
+    "flag"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "runtime"
+    "strconv"
+
+    "github.com/franciscobonand/symb-regr-gp/experiment"
+    "github.com/franciscobonand/symb-regr-gp/stats"
+)
+
+// sweepParams are the column names of the swept parameters
+var sweepParams = []string{"config", "popsize", "gens", "cxprob", "mutprob", "selector", "toursize", "elitism"}
+
+// sweepMain runs the 'sweep' command, which executes the cartesian product of
+// lists or ranges of parameters and writes a table with a row per configuration
+func sweepMain(args []string) {
+    // ./symb-regr-gp sweep -popsize 50,100 -gens 10:50:20 -selector tour,lex -runs 10 -out sweep.csv
+    var popSizes, gens, cxProbs, mutProbs, selectors, tourSizes, elitisms, out, runsfile string
+    fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+    fs.StringVar(&popSizes, "popsize", "20", "population sizes")
+    fs.StringVar(&gens, "gens", "10", "numbers of generations")
+    fs.StringVar(&cxProbs, "cxprob", "0.9", "crossover probabilities")
+    fs.StringVar(&mutProbs, "mutprob", "0.05", "mutation probabilities")
+    fs.StringVar(&selectors, "selector", "tour", "comma separated selection methods")
+    fs.StringVar(&tourSizes, "toursize", "2", "tournament sizes")
+    fs.StringVar(&elitisms, "elitism", "0", "numbers of best members of elitism")
+    fs.IntVar(&parallel, "parallel", runtime.NumCPU(), "maximum number of configurations executed at the same time")
+    fs.StringVar(&out, "out", "", "csv file to write the results of every configuration into (default stdout)")
+    fs.StringVar(&runsfile, "runsfile", "", "if set, writes the final results of every run of every configuration into the given csv file")
+    commonFlags(fs)
+    fs.Usage = func() {
+        fmt.Fprintln(fs.Output(), "Usage of sweep (numeric parameters take comma separated values and 'start:end[:step]' ranges):")
+        fs.PrintDefaults()
+    }
+    fs.Parse(args)
+
+    var grid experiment.Grid
+    var err error
+    if grid.PopSize, err = experiment.ParseInts(popSizes); err != nil {
+        panic(err.Error())
+    }
+    if grid.Generations, err = experiment.ParseInts(gens); err != nil {
+        panic(err.Error())
+    }
+    if grid.CrossProb, err = experiment.ParseFloats(cxProbs); err != nil {
+        panic(err.Error())
+    }
+    if grid.MutProb, err = experiment.ParseFloats(mutProbs); err != nil {
+        panic(err.Error())
+    }
+    if grid.TournamentSize, err = experiment.ParseInts(tourSizes); err != nil {
+        panic(err.Error())
+    }
+    if grid.Elitism, err = experiment.ParseInts(elitisms); err != nil {
+        panic(err.Error())
+    }
+    grid.Selector = experiment.ParseStrings(selectors)
+
+    cfgs := grid.Configs(newConfig())
+    for _, cfg := range cfgs {
+        if err := cfg.Validate(); err != nil {
+            panic(err.Error())
+        }
+    }
+    if !allPositiveInts(runs, parallel) {
+        panic("Invalid value for runs or parallel, must be a positive integer")
+    }
+
+    data := readDatasets()
+    for i := range cfgs {
+        setClasses(&cfgs[i], data)
+    }
+    var w io.Writer = os.Stdout
+    if out != "" {
+        f, err := stats.CreateFile(out)
+        if err != nil {
+            panic(err.Error())
+        }
+        defer f.Close()
+        w = f
+    }
+
+    done := func(i int, results []experiment.Result) {
+        fmt.Fprintf(os.Stderr, "configuration %d/%d done\n", i+1, len(cfgs))
+    }
+    results, err := experiment.Sweep(cfgs, data, runs, parallel, seed, done)
+    if err != nil {
+        panic(err.Error())
+    }
+
+    better := experiment.NewEvaluator(cfgs[0], data.Train).CompareFitness
+    if err := writeSweep(w, cfgs, results, better); err != nil {
+        panic(err.Error())
+    }
+    if runsfile != "" {
+        if err := writeSweepRuns(runsfile, cfgs, results); err != nil {
+            fmt.Println("(ERROR) failed to write runs file:", err.Error())
+        }
+    }
+}
+
+// sweepValues returns the values of the swept parameters of the i-th configuration
+func sweepValues(i int, cfg experiment.Config) []string {
+    return []string{
+        strconv.Itoa(i),
+        strconv.Itoa(cfg.PopSize),
+        strconv.Itoa(cfg.Generations),
+        strconv.FormatFloat(cfg.CrossProb, 'g', -1, 64),
+        strconv.FormatFloat(cfg.MutProb, 'g', -1, 64),
+        cfg.Selector,
+        strconv.Itoa(cfg.TournamentSize),
+        strconv.Itoa(cfg.Elitism),
+    }
+}
+
+// writeSweep writes a row per configuration with the distribution of the final
+// train fitness, test fitness and size of its runs, and its best train fitness
+func writeSweep(w io.Writer, cfgs []experiment.Config, results [][]experiment.Result, better func(a, b float64) bool) error {
+    cw := csv.NewWriter(w)
+    header := append([]string{}, sweepParams...)
+    header = append(header, "runs")
+    header = append(header, stats.DistributionHeader("trainfit")...)
+    header = append(header, "trainfit_best")
+    header = append(header, stats.DistributionHeader("testfit")...)
+    header = append(header, stats.DistributionHeader("size")...)
+    if err := cw.Write(header); err != nil {
+        return err
+    }
+    for i, cfg := range cfgs {
+        train := make([]float64, len(results[i]))
+        test := make([]float64, len(results[i]))
+        size := make([]float64, len(results[i]))
+        best := math.NaN()
+        for r, res := range results[i] {
+            s := res.Summary()
+            train[r], test[r], size[r] = s.TrainFit, s.TestFit, float64(s.Size)
+            if math.IsNaN(best) || better(s.TrainFit, best) {
+                best = s.TrainFit
+            }
+        }
+        vals := append([]float64{}, stats.Describe(train).Values()...)
+        vals = append(vals, best)
+        vals = append(vals, stats.Describe(test).Values()...)
+        vals = append(vals, stats.Describe(size).Values()...)
+
+        line := append(sweepValues(i, cfg), strconv.Itoa(len(results[i])))
+        for _, v := range vals {
+            line = append(line, strconv.FormatFloat(v, 'f', 6, 64))
+        }
+        if err := cw.Write(line); err != nil {
+            return err
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// writeSweepRuns writes the final results of every run of every configuration into the given csv file
+func writeSweepRuns(fname string, cfgs []experiment.Config, results [][]experiment.Result) error {
+    f, err := stats.CreateFile(fname)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    cw := csv.NewWriter(f)
+    header := append(append([]string{}, sweepParams...), "run", "seed", "trainfit", "valfit", "testfit", "size", "best")
+    if err := cw.Write(header); err != nil {
+        return err
+    }
+    for i, cfg := range cfgs {
+        for _, res := range results[i] {
+            s := res.Summary()
+            line := append(sweepValues(i, cfg),
+                strconv.FormatInt(s.Run, 10),
+                strconv.FormatInt(s.Seed, 10),
+                strconv.FormatFloat(s.TrainFit, 'f', 6, 64),
+                strconv.FormatFloat(s.ValFit, 'f', 6, 64),
+                strconv.FormatFloat(s.TestFit, 'f', 6, 64),
+                strconv.Itoa(s.Size),
+                s.Best,
+            )
+            if err := cw.Write(line); err != nil {
+                return err
+            }
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}